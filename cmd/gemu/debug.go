@@ -0,0 +1,91 @@
+/*
+		gemu - the gameboy emulator
+				<3 m0x
+	    __________________________
+	   |                          |
+	   | .----------------------. |
+	   | |  .----------------.  | |
+	   | |  |                |  | |
+	   | |))|                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  '----------------'  | |
+	   | |__GAME BOY____________/ |
+	   |          ________        |
+	   |    .    (Nintendo)       |
+	   |  _| |_   """"""""   .-.  |
+	   |-[_   _]-       .-. (   ) |
+	   |   |_|         (   ) '-'  |
+	   |    '           '-'   A   |
+	   |                 B        |
+	   |          ___   ___       |
+	   |         (___) (___)  ,., |
+	   |        select start ;:;: |
+	   |                    ,;:;' /
+	   |                   ,:;:'.'
+	   '-----------------------`
+*/
+package main
+
+import (
+	"flag"
+	"fmt"
+	"gemu/pkg/debugger"
+	"os"
+)
+
+// debugMain runs the interactive debugger, "gemu debug rom.gb"
+func debugMain(args []string) {
+	fs := flag.NewFlagSet("debug", flag.ExitOnError)
+	var opts options
+	opts.register(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: gemu debug [flags] rom.gb\n\nflags:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	gemu, err := opts.gameBoy()
+	if err != nil {
+		fmt.Println("[!] " + err.Error())
+		os.Exit(1)
+	}
+
+	// Nothing is rendered while debugging, so there is no frame channel
+	if err := gemu.Init(nil); err != nil {
+		fmt.Println("[!] gemu init failed - " + err.Error())
+		os.Exit(1)
+	}
+	if err := gemu.LoadROM(fs.Arg(0)); err != nil {
+		fmt.Println("[!] failed to load ROM - " + err.Error())
+		os.Exit(1)
+	}
+
+	recorder, _, err := opts.startMovie(gemu)
+	if err != nil {
+		fmt.Println("[!] " + err.Error())
+		os.Exit(1)
+	}
+	tracer, err := opts.startTrace(gemu)
+	if err != nil {
+		fmt.Println("[!] " + err.Error())
+		os.Exit(1)
+	}
+
+	err = debugger.New(gemu, os.Stdin, os.Stdout).Run()
+	stopRecording(recorder)
+	if tracer != nil {
+		tracer.Close()
+	}
+	if err != nil {
+		fmt.Println("[!] debugger failed - " + err.Error())
+		os.Exit(1)
+	}
+}
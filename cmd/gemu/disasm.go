@@ -0,0 +1,175 @@
+/*
+		gemu - the gameboy emulator
+				<3 m0x
+	    __________________________
+	   |                          |
+	   | .----------------------. |
+	   | |  .----------------.  | |
+	   | |  |                |  | |
+	   | |))|                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  '----------------'  | |
+	   | |__GAME BOY____________/ |
+	   |          ________        |
+	   |    .    (Nintendo)       |
+	   |  _| |_   """"""""   .-.  |
+	   |-[_   _]-       .-. (   ) |
+	   |   |_|         (   ) '-'  |
+	   |    '           '-'   A   |
+	   |                 B        |
+	   |          ___   ___       |
+	   |         (___) (___)  ,., |
+	   |        select start ;:;: |
+	   |                    ,;:;' /
+	   |                   ,:;:'.'
+	   '-----------------------`
+*/
+package main
+
+import (
+	"flag"
+	"fmt"
+	"gemu/pkg/disasm"
+	"gemu/pkg/symbols"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// disasmMain runs the disassembler, "gemu disasm rom.gb"
+func disasmMain(args []string) {
+	fs := flag.NewFlagSet("disasm", flag.ExitOnError)
+	bank := fs.Int("bank", -1, "ROM bank to disassemble, bank 0 is mapped at $0000-$3FFF and every other bank at $4000-$7FFF")
+	start := fs.String("start", "", "first address to disassemble, hex or a label (default: start of the bank)")
+	end := fs.String("end", "", "last address to disassemble, hex or a label (default: end of the bank)")
+	symFile := fs.String("sym", "", "RGBDS .sym or .map file to annotate the disassembly with (default: the ROM's name with a .sym or .map extension, if there is one)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: gemu disasm [flags] rom.gb\n\nflags:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	rom, err := os.ReadFile(fs.Arg(0))
+	if err != nil {
+		fmt.Println("[!] failed to load ROM - " + err.Error())
+		os.Exit(1)
+	}
+
+	var syms *symbols.Table
+	if *symFile != "" {
+		syms, err = symbols.Load(*symFile)
+	} else {
+		syms, _, err = symbols.LoadBeside(fs.Arg(0))
+	}
+	if err != nil {
+		fmt.Println("[!] failed to load symbols - " + err.Error())
+		os.Exit(1)
+	}
+
+	// Work out the range, which has to stay within the bank's window
+	b := *bank
+	lo, hi := uint16(0x0000), uint16(0x3FFF)
+	if b > 0 {
+		lo, hi = 0x4000, 0x7FFF
+	}
+	if *start != "" {
+		// Starting at a label in switchable ROM picks its bank too
+		var startBank int
+		if lo, startBank, err = parseAddr(*start, syms); err != nil {
+			fmt.Println("[!] invalid -start - " + err.Error())
+			os.Exit(2)
+		}
+		if *end == "" {
+			hi = lo | 0x3FFF
+		}
+		if b < 0 && startBank >= 0 {
+			b = startBank
+		}
+	}
+	if *end != "" {
+		if hi, _, err = parseAddr(*end, syms); err != nil {
+			fmt.Println("[!] invalid -end - " + err.Error())
+			os.Exit(2)
+		}
+	}
+	if b < 0 {
+		// Without a bank, addresses in $4000-$7FFF come from bank 1
+		b = 0
+		if lo >= 0x4000 {
+			b = 1
+		}
+	}
+	if lo > hi || hi > 0x7FFF || (b > 0 && lo < 0x4000) {
+		fmt.Printf("[!] invalid range $%04X-$%04X for bank %d\n", lo, hi, b)
+		os.Exit(2)
+	}
+
+	// Read straight from the ROM image, mapping $4000-$7FFF to the selected bank
+	read := func(addr uint16) uint8 {
+		off := bankOf(b, addr)*0x4000 + int(addr&0x3FFF)
+		if off >= len(rom) {
+			return 0xFF
+		}
+		return rom[off]
+	}
+	labels := func(addr uint16) (string, bool) {
+		return syms.Lookup(bankOf(b, addr), addr)
+	}
+
+	for _, ins := range disasm.Range(read, lo, hi) {
+		if label, ok := labels(ins.Addr); ok {
+			fmt.Printf("%s:\n", label)
+		}
+
+		var raw strings.Builder
+		for _, by := range ins.Bytes {
+			fmt.Fprintf(&raw, "%02X ", by)
+		}
+		fmt.Printf("  %02X:%04X  %-9s  %s\n", bankOf(b, ins.Addr), ins.Addr, raw.String(), ins.Format(labels))
+	}
+}
+
+// bankOf returns the bank an address is in, given the bank mapped at $4000-$7FFF
+func bankOf(bank int, addr uint16) int {
+	if addr < 0x4000 {
+		return 0
+	}
+
+	// Bank 0 is always at $0000-$3FFF, the cartridge maps bank 1 in its place at $4000-$7FFF
+	if bank == 0 {
+		return 1
+	}
+	return bank
+}
+
+// parseAddr parses a hex address or a label with an optional offset, returning the label's bank if it's in switchable ROM
+func parseAddr(s string, syms *symbols.Table) (uint16, int, error) {
+	addr, err := parseHex(s)
+	if err == nil || syms == nil {
+		return addr, -1, err
+	}
+
+	sym, err := syms.Resolve(s)
+	if err != nil {
+		return 0, -1, err
+	}
+	if sym.Addr < 0x4000 || sym.Addr > 0x7FFF {
+		return sym.Addr, -1, nil
+	}
+	return sym.Addr, sym.Bank, nil
+}
+
+// parseHex parses a hex address, with or without a $ or 0x prefix
+func parseHex(s string) (uint16, error) {
+	s = strings.TrimPrefix(strings.TrimPrefix(strings.ToLower(s), "0x"), "$")
+	n, err := strconv.ParseUint(s, 16, 16)
+	return uint16(n), err
+}
@@ -0,0 +1,142 @@
+/*
+		gemu - the gameboy emulator
+				<3 m0x
+	    __________________________
+	   |                          |
+	   | .----------------------. |
+	   | |  .----------------.  | |
+	   | |  |                |  | |
+	   | |))|                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  '----------------'  | |
+	   | |__GAME BOY____________/ |
+	   |          ________        |
+	   |    .    (Nintendo)       |
+	   |  _| |_   """"""""   .-.  |
+	   |-[_   _]-       .-. (   ) |
+	   |   |_|         (   ) '-'  |
+	   |    '           '-'   A   |
+	   |                 B        |
+	   |          ___   ___       |
+	   |         (___) (___)  ,., |
+	   |        select start ;:;: |
+	   |                    ,;:;' /
+	   |                   ,:;:'.'
+	   '-----------------------`
+*/
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"gemu/pkg/cpu"
+	"gemu/pkg/gb"
+	"os"
+	"strings"
+)
+
+// headlessOptions are the flags for running without a display
+type headlessOptions struct {
+	enabled bool
+	frames  uint64
+	until   string
+}
+
+// register adds the headless flags to a flag set
+func (h *headlessOptions) register(fs *flag.FlagSet) {
+	fs.BoolVar(&h.enabled, "headless", false, "run without a display, as fast as possible, printing serial output to stdout")
+	fs.Uint64Var(&h.frames, "frames", 0, "with -headless, stop after this many frames, 0 for no limit")
+	fs.StringVar(&h.until, "until", "", "with -headless, stop when a condition is met, exiting with 1 if -frames runs out first:\n"+
+		"  pc=<addr|label>  PC reaches an address\n"+
+		"  serial=<text>    the serial output contains text\n"+
+		"  lockup           the CPU locks up on an unused opcode")
+}
+
+// run runs the GameBoy until it's done, returning the exit code
+func (h *headlessOptions) run(gemu *gb.GameBoy) int {
+	done, err := h.condition(gemu)
+	if err != nil {
+		fmt.Println("[!] invalid -until flag - " + err.Error())
+		return 2
+	}
+
+	var frames uint64
+	for h.frames == 0 || frames < h.frames {
+		if err := gemu.RunFrame(); err != nil {
+			fmt.Printf("[!] gemu failed after %d frames (%s fault) - %s\n", frames, gb.Classify(err), err)
+			return 1
+		}
+		frames++
+
+		if done != nil && done() {
+			fmt.Printf("[*] %s after %d frames\n", h.until, frames)
+			return 0
+		}
+	}
+
+	if done != nil {
+		fmt.Printf("[!] ran %d frames without %s\n", frames, h.until)
+		return 1
+	}
+	fmt.Printf("[*] ran %d frames\n", frames)
+	return 0
+}
+
+// condition parses -until, returning a check that's true once the condition has been met.
+// The serial output is always echoed to stdout, as that's how test ROMs report their results.
+func (h *headlessOptions) condition(gemu *gb.GameBoy) (func() bool, error) {
+	var output strings.Builder
+	gemu.MMU().Serial().OnSend = func(value uint8) {
+		output.WriteByte(value)
+		os.Stdout.Write([]byte{value})
+	}
+
+	if h.until == "" {
+		return nil, nil
+	}
+
+	name, arg := h.until, ""
+	if i := strings.IndexByte(h.until, '='); i >= 0 {
+		name, arg = h.until[:i], h.until[i+1:]
+	}
+
+	switch name {
+	case "pc":
+		addr, err := parseHex(arg)
+		if err != nil {
+			sym, serr := gemu.Symbols.Resolve(arg)
+			if serr != nil {
+				return nil, fmt.Errorf("bad address %q", arg)
+			}
+			addr = sym.Addr
+		}
+
+		// Chain onto any tracer, so both see every instruction
+		hit := false
+		next := gemu.CPU().OnInstruction
+		gemu.CPU().OnInstruction = func(reg cpu.Registers) {
+			if next != nil {
+				next(reg)
+			}
+			if reg.PC == addr {
+				hit = true
+			}
+		}
+		return func() bool { return hit }, nil
+
+	case "serial":
+		if arg == "" {
+			return nil, errors.New("serial needs the text to wait for, e.g. serial=Passed")
+		}
+		return func() bool { return strings.Contains(output.String(), arg) }, nil
+
+	case "lockup":
+		return gemu.Locked, nil
+	}
+	return nil, fmt.Errorf("unknown condition %q, expected pc=, serial= or lockup", name)
+}
@@ -31,46 +31,314 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
+	"gemu/pkg/debugger"
 	"gemu/pkg/gb"
+	"gemu/pkg/logger"
+	"gemu/pkg/movie"
+	"gemu/pkg/palette"
+	"gemu/pkg/ppu"
 	"gemu/pkg/render"
-
-	"github.com/veandco/go-sdl2/sdl"
+	"gemu/pkg/symbols"
+	"gemu/pkg/trace"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
 )
 
+// options are the flags shared by all of gemu's modes
+type options struct {
+	logLevels string
+	onError   string
+	lenient   bool
+	symFile   string
+
+	// Instruction tracing
+	traceFile    string
+	tracePC      string
+	traceBank    int
+	traceGzip    bool
+	traceBoot    bool
+	traceSymbols bool
+
+	// Movies
+	recordFile string
+	playFile   string
+	rtcStart   time.Duration
+}
+
+// register adds the shared flags to a flag set
+func (o *options) register(fs *flag.FlagSet) {
+	fs.StringVar(&o.logLevels, "log", "", "log levels, either a level for everything or category=level pairs, e.g. \"info,mmu=trace,stack=debug\"\n"+
+		"levels: off, error, warn, info, debug, trace\n"+
+		"categories: gb, cpu, unimplemented, stack, mmu, timing, cartridge")
+	fs.StringVar(&o.onError, "on-error", "halt", "what to do when emulation raises an error: halt, continue (log and keep going) or break (into the debugger)")
+	fs.BoolVar(&o.lenient, "lenient", false, "skip over unused opcodes with a warning instead of locking up the CPU, useful when debugging homebrew")
+	fs.StringVar(&o.symFile, "sym", "", "RGBDS .sym or .map file with the ROM's labels (default: the ROM's name with a .sym or .map extension, if there is one)")
+
+	fs.StringVar(&o.traceFile, "trace", "", "write an instruction trace in the Gameboy-Doctor format to a file, gzip compressed if it ends in .gz")
+	fs.StringVar(&o.tracePC, "trace-pc", "", "only trace instructions in these hex PC ranges, e.g. \"0150-01FF,4000-7FFF\"")
+	fs.IntVar(&o.traceBank, "trace-bank", -1, "only trace instructions in this ROM bank")
+	fs.BoolVar(&o.traceGzip, "trace-gzip", false, "gzip compress the trace")
+	fs.BoolVar(&o.traceBoot, "trace-boot", false, "trace the boot ROM too, reference traces start at 0100 after it")
+	fs.BoolVar(&o.traceSymbols, "trace-symbols", false, "append the label of each instruction to the trace, which reference traces don't have")
+
+	fs.StringVar(&o.recordFile, "record", "", "record the joypad input from power on to a movie file, which is written on exit")
+	fs.StringVar(&o.playFile, "play", "", "play back a movie recorded with -record, using the settings it was recorded with")
+	fs.DurationVar(&o.rtcStart, "rtc", 0, "time the cartridge's real time clock starts at, e.g. \"50h30m\" (it runs on emulated time)")
+}
+
+// startMovie starts recording or playing a movie on a GameBoy with its ROM loaded, if one was asked for
+func (o *options) startMovie(gemu *gb.GameBoy) (*movie.Recorder, *movie.Player, error) {
+	if o.recordFile != "" && o.playFile != "" {
+		return nil, nil, fmt.Errorf("-record and -play can't be used together")
+	}
+
+	if o.recordFile != "" {
+		r, err := movie.Record(gemu, o.recordFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to start recording - %w", err)
+		}
+		return r, nil, nil
+	}
+
+	if o.playFile != "" {
+		m, err := movie.Load(o.playFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to load movie - %w", err)
+		}
+		p, err := movie.Play(gemu, m)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to play movie - %w", err)
+		}
+		return nil, p, nil
+	}
+	return nil, nil, nil
+}
+
+// stopRecording writes the movie being recorded, if there is one
+func stopRecording(r *movie.Recorder) {
+	if r == nil {
+		return
+	}
+	if err := r.Close(); err != nil {
+		fmt.Println("[!] failed to write movie - " + err.Error())
+		return
+	}
+	fmt.Printf("Recorded %d frames to %s\n", len(r.Movie.Input), r.Path)
+}
+
+// startTrace starts the instruction trace for a GameBoy with its ROM loaded, if one was asked for
+func (o *options) startTrace(gemu *gb.GameBoy) (*trace.Tracer, error) {
+	if o.traceFile == "" {
+		return nil, nil
+	}
+
+	ranges, err := trace.ParseRanges(o.tracePC)
+	if err != nil {
+		return nil, fmt.Errorf("invalid -trace-pc flag - %w", err)
+	}
+
+	t, err := trace.Create(o.traceFile, o.traceGzip || strings.HasSuffix(o.traceFile, ".gz"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create trace - %w", err)
+	}
+	t.Filter = trace.Filter{Ranges: ranges, Bank: o.traceBank, BootROM: o.traceBoot}
+	if o.traceSymbols {
+		t.Symbols = gemu.Symbols
+	}
+	t.Attach(gemu)
+	return t, nil
+}
+
+// gameBoy applies the shared flags, returning a GameBoy configured by them
+func (o *options) gameBoy() (*gb.GameBoy, error) {
+	// Setup logging
+	if err := logger.Parse(o.logLevels); err != nil {
+		return nil, fmt.Errorf("invalid -log flag - %w", err)
+	}
+
+	// Setup error handling
+	policy, err := gb.ParseErrorPolicy(o.onError)
+	if err != nil {
+		return nil, fmt.Errorf("invalid -on-error flag - %w", err)
+	}
+
+	gemu := &gb.GameBoy{Policy: policy, Lenient: o.lenient, RTCStart: o.rtcStart, Events: make(chan gb.Event, 4)}
+
+	// Symbols next to the ROM are picked up when it's loaded, unless they're given explicitly
+	if o.symFile != "" {
+		if gemu.Symbols, err = symbols.Load(o.symFile); err != nil {
+			return nil, fmt.Errorf("failed to load symbols - %w", err)
+		}
+	}
+	return gemu, nil
+}
+
 func main() {
+	// Subcommands get their own flags
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "debug":
+			debugMain(os.Args[2:])
+			return
+		case "disasm":
+			disasmMain(os.Args[2:])
+			return
+		case "trace-diff":
+			traceDiffMain(os.Args[2:])
+			return
+		case "testrom":
+			testROMMain(os.Args[2:])
+			return
+		}
+	}
+
+	var opts options
+	opts.register(flag.CommandLine)
+	var headless headlessOptions
+	headless.register(flag.CommandLine)
+	var videos videoRecorder
+	videos.register(flag.CommandLine)
+	display := render.DefaultOptions
+	flag.IntVar(&display.Scale, "scale", display.Scale, fmt.Sprintf("window size, as a multiple of the GameBoy's screen (1-%d)", render.MaxScale))
+	flag.BoolVar(&display.Fullscreen, "fullscreen", display.Fullscreen, "start fullscreen")
+	flag.BoolVar(&display.Smooth, "smooth", display.Smooth, "smooth the screen when scaling it, instead of keeping the pixels sharp")
+	flag.BoolVar(&display.IntegerScale, "integer-scale", display.IntegerScale, "only scale the screen by whole multiples, with black borders around it")
+	flag.Float64Var(&display.Ghosting, "ghosting", display.Ghosting, fmt.Sprintf("blend each frame with the last like the DMG's slow LCD, how much of the last frame is left (0-%g)", render.MaxPersistence))
+	flag.BoolVar(&display.Grid, "grid", display.Grid, "show the gaps between the pixels")
+	flag.BoolVar(&display.LCDMask, "lcd-mask", display.LCDMask, "tint the pixels like an LCD's red, green and blue subpixels")
+	showOSD := flag.Bool("osd", true, "show the FPS, speed and link cable status over the game, O toggles it")
+	paletteName := flag.String("palette", display.Palette.Name, "palette to colour the screen with, one of grey, dmg, pocket and light or a palette file")
+	rewindSeconds := flag.Float64("rewind", 20, "seconds of gameplay kept for rewinding with Backspace, 0 turns rewinding off")
+	rewindInterval := flag.Int("rewind-interval", 2, "frames between rewind snapshots, higher uses less memory but rewinding has to replay more")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: gemu [flags] [rom.gb]\n"+
+			"       gemu debug [flags] rom.gb\n"+
+			"       gemu disasm [flags] rom.gb\n"+
+			"       gemu trace-diff [flags] rom.gb reference.log\n"+
+			"       gemu testrom [flags] rom.gb|dir...\n\nflags:\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
 	fmt.Println("gemu")
 
+	gemu, err := opts.gameBoy()
+	if err != nil {
+		fmt.Println("[!] " + err.Error())
+		return
+	}
+
 	// Setup communication channels
-	renderFrame := make(chan *sdl.Surface, 4)
+	renderFrame := make(chan *ppu.Frame, 4)
+	actions := make(chan render.Action, 4)
 	renderStopped := make(chan struct{})
 	stopRender := make(chan struct{})
 	gbStopped := make(chan struct{})
 	stopGB := make(chan struct{})
 
-	// Initialize SDL
-	render.Init()
+	if display.Palette, err = palette.Get(*paletteName); err != nil {
+		fmt.Println("[!] invalid -palette flag - " + err.Error())
+		return
+	}
+
+	// Initialize SDL, unless there's no display
+	if headless.enabled {
+		renderFrame = nil
+	} else {
+		render.Init()
+	}
 
 	// Initialize GameBoy
-	gemu := gb.GameBoy{}
 	if err := gemu.Init(renderFrame); err != nil {
 		fmt.Println("[!] gemu init failed - " + err.Error())
 		return
 	}
+	if rom := flag.Arg(0); rom != "" {
+		if err := gemu.LoadROM(rom); err != nil {
+			fmt.Println("[!] failed to load ROM - " + err.Error())
+			return
+		}
+	}
+	if !headless.enabled {
+		gemu.EnableRewind(*rewindSeconds, *rewindInterval)
+	}
+	recorder, player, err := opts.startMovie(gemu)
+	if err != nil {
+		fmt.Println("[!] " + err.Error())
+		return
+	}
+	defer stopRecording(recorder)
+	tracer, err := opts.startTrace(gemu)
+	if err != nil {
+		fmt.Println("[!] " + err.Error())
+		return
+	}
+	if tracer != nil {
+		defer tracer.Close()
+	}
+	if err := videos.attach(gemu, display.Palette.Palettes); err != nil {
+		fmt.Println("[!] " + err.Error())
+		return
+	}
+	defer videos.close()
+	if gemu.Policy == gb.PolicyBreak {
+		gemu.OnBreak = debugger.New(gemu, os.Stdin, os.Stdout).Break
+	}
+
+	// Report hardware events as they happen
+	go func() {
+		for e := range gemu.Events {
+			switch e.Kind {
+			case gb.EventLockup:
+				fmt.Println("[!] the CPU has locked up - " + e.Err.Error())
+			}
+		}
+	}()
+
+	// Without a display, run flat out until done
+	if headless.enabled {
+		// A movie plays to its end, unless told otherwise
+		if player != nil && headless.frames == 0 && headless.until == "" {
+			headless.frames = uint64(player.Len())
+		}
+
+		code := headless.run(gemu)
+		stopRecording(recorder)
+		videos.close()
+		if tracer != nil {
+			tracer.Close()
+		}
+		os.Exit(code)
+	}
+
+	// Carry out hotkey presses between frames, telling the user how they went on screen
+	osd := render.NewOSD(*showOSD)
+	go func() {
+		for a := range actions {
+			a := a
+			gemu.Do(func() { runAction(gemu, osd, &videos, a) })
+		}
+	}()
+	go reportStats(gemu, osd, &videos)
 
 	// Launch Renderer and Emulator :3
 	go func() {
-		err := render.Run(renderFrame, renderStopped, stopRender)
+		err := render.Run(display, osd, renderFrame, actions, renderStopped, stopRender)
 		if err != nil {
 			fmt.Println("[!] render routine failed - " + err.Error())
 			close(renderStopped)
 		}
 	}()
 	go func() {
-		err := gemu.Run(gbStopped, stopGB)
-		if err != nil {
-			fmt.Println("[!] gemu routine failed - " + err.Error())
-			close(gbStopped)
+		// Only signal once Run has returned, so the recorders aren't closed while frames are still going to them
+		defer close(gbStopped)
+		if err := gemu.Run(stopGB); err != nil {
+			fmt.Printf("[!] gemu routine failed (%s fault) - %s\n", gb.Classify(err), err)
 		}
 	}()
 
@@ -85,3 +353,98 @@ func main() {
 		<-renderStopped
 	}
 }
+
+// runAction carries out a hotkey press from the renderer
+func runAction(gemu *gb.GameBoy, osd *render.OSD, videos *videoRecorder, a render.Action) {
+	switch a.Kind {
+	case render.ActionSaveState:
+		if err := gemu.SaveSlot(a.Slot); err != nil {
+			fmt.Printf("[!] failed to save state to slot %d - %s\n", a.Slot, err)
+			osd.Message("Failed to save state %d", a.Slot)
+			return
+		}
+		fmt.Printf("Saved state to slot %d\n", a.Slot)
+		osd.Message("State %d saved", a.Slot)
+
+	case render.ActionLoadState:
+		if err := gemu.LoadSlot(a.Slot); err != nil {
+			fmt.Printf("[!] failed to load state from slot %d - %s\n", a.Slot, err)
+			if errors.Is(err, os.ErrNotExist) {
+				osd.Message("No state in slot %d", a.Slot)
+			} else {
+				osd.Message("Failed to load state %d", a.Slot)
+			}
+			return
+		}
+		fmt.Printf("Loaded state from slot %d\n", a.Slot)
+		osd.Message("State %d loaded", a.Slot)
+
+	case render.ActionRewind:
+		gemu.SetRewinding(a.Held)
+
+	case render.ActionButtons:
+		gemu.SetButtons(a.Buttons)
+
+	case render.ActionSpeed:
+		gemu.SetSpeed(a.Speed)
+
+	case render.ActionPause:
+		gemu.SetPaused(a.Held)
+
+	case render.ActionAdvance:
+		gemu.AdvanceFrame()
+
+	case render.ActionScreenshot:
+		path, err := gemu.Screenshot(a.Palettes, a.Scale)
+		if err != nil {
+			fmt.Println("[!] failed to save screenshot - " + err.Error())
+			osd.Message("Failed to save screenshot")
+			return
+		}
+		fmt.Println("Saved screenshot to " + path)
+		osd.Message("Screenshot saved")
+
+	case render.ActionVideo:
+		if err := videos.toggle(a.Palettes); err != nil {
+			fmt.Println("[!] " + err.Error())
+			osd.Message("Failed to record video")
+		} else if videos.recording() {
+			osd.Message("Recording video")
+		} else {
+			osd.Message("Video saved")
+		}
+	}
+}
+
+// reportStats updates the stats on the OSD every second, for as long as gemu runs. While gemu is too
+// busy to get to the last update, the next ones are skipped rather than queued up behind it.
+func reportStats(gemu *gb.GameBoy, osd *render.OSD, videos *videoRecorder) {
+	var lastFrame uint64
+	last := time.Now()
+	var pending int32
+	for range time.Tick(time.Second) {
+		if !atomic.CompareAndSwapInt32(&pending, 0, 1) {
+			continue
+		}
+		gemu.Do(func() {
+			defer atomic.StoreInt32(&pending, 0)
+			now, frame := time.Now(), gemu.FrameCount()
+
+			// Rewinding and loading states move the frame count back, which isn't any frames run
+			var fps float64
+			if frame > lastFrame {
+				fps = float64(frame-lastFrame) / now.Sub(last).Seconds()
+			}
+			lastFrame, last = frame, now
+
+			link := render.LinkNone
+			if serial := gemu.MMU().Serial(); serial.Connected() {
+				link = render.LinkIdle
+				if serial.Transferring() {
+					link = render.LinkTransferring
+				}
+			}
+			osd.SetStats(render.Stats{FPS: fps, Link: link, Recording: videos.recording()})
+		})
+	}
+}
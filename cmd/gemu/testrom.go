@@ -0,0 +1,118 @@
+/*
+		gemu - the gameboy emulator
+				<3 m0x
+	    __________________________
+	   |                          |
+	   | .----------------------. |
+	   | |  .----------------.  | |
+	   | |  |                |  | |
+	   | |))|                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  '----------------'  | |
+	   | |__GAME BOY____________/ |
+	   |          ________        |
+	   |    .    (Nintendo)       |
+	   |  _| |_   """"""""   .-.  |
+	   |-[_   _]-       .-. (   ) |
+	   |   |_|         (   ) '-'  |
+	   |    '           '-'   A   |
+	   |                 B        |
+	   |          ___   ___       |
+	   |         (___) (___)  ,., |
+	   |        select start ;:;: |
+	   |                    ,;:;' /
+	   |                   ,:;:'.'
+	   '-----------------------`
+*/
+package main
+
+import (
+	"flag"
+	"fmt"
+	"gemu/pkg/logger"
+	"gemu/pkg/testrom"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// testROMMain runs test ROMs headlessly and summarises the results, "gemu testrom roms/"
+func testROMMain(args []string) {
+	fs := flag.NewFlagSet("testrom", flag.ExitOnError)
+	logLevels := fs.String("log", "off", "log levels, see gemu -help")
+	frames := fs.Uint64("frames", testrom.DefaultMaxFrames, "the most frames to run each ROM for")
+	timeout := fs.Duration("timeout", testrom.DefaultTimeout, "the most real time to spend on each ROM")
+	boot := fs.Bool("boot", false, "run the boot ROM first, instead of starting in the state it leaves behind")
+	reference := fs.String("reference", "", "compare the screen against a reference PNG instead of looking for a reported result,\n"+
+		"either a file or a directory of them named after each ROM, e.g. dmg-acid2.png for dmg-acid2.gb")
+	shotFrames := fs.Uint64("screenshot-frames", 0, "with -reference, take the screenshot after this many frames instead of at LD B,B")
+	diffDir := fs.String("diff-dir", "", "with -reference, where to write diff images of failures (default: next to each ROM)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: gemu testrom [flags] rom.gb|dir...\n\n"+
+			"Runs Blargg and Mooneye test ROMs without a display and reports which pass,\n"+
+			"or compares the screen against reference screenshots for PPU tests like dmg-acid2.\n\nflags:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	if err := logger.Parse(*logLevels); err != nil {
+		fmt.Println("[!] invalid -log flag - " + err.Error())
+		os.Exit(2)
+	}
+
+	var roms []string
+	for _, path := range fs.Args() {
+		found, err := testrom.Find(path)
+		if err != nil {
+			fmt.Println("[!] " + err.Error())
+			os.Exit(1)
+		}
+		roms = append(roms, found...)
+	}
+
+	var refDir bool
+	if *reference != "" {
+		info, err := os.Stat(*reference)
+		if err != nil {
+			fmt.Println("[!] " + err.Error())
+			os.Exit(1)
+		}
+		refDir = info.IsDir()
+	}
+
+	var results []testrom.Result
+	passed := true
+	for _, rom := range roms {
+		opts := testrom.Options{MaxFrames: *frames, Timeout: *timeout, BootROM: *boot}
+		if *reference != "" {
+			base := strings.TrimSuffix(filepath.Base(rom), filepath.Ext(rom))
+			opts.Reference, opts.Frames = *reference, *shotFrames
+			if refDir {
+				opts.Reference = filepath.Join(*reference, base+".png")
+			}
+			opts.Diff = strings.TrimSuffix(rom, filepath.Ext(rom)) + "-diff.png"
+			if *diffDir != "" {
+				opts.Diff = filepath.Join(*diffDir, base+"-diff.png")
+			}
+		}
+
+		r := testrom.Run(rom, opts)
+		fmt.Printf("%-7s %s\n", r.Status, rom)
+		results = append(results, r)
+		passed = passed && r.Status == testrom.StatusPass
+	}
+
+	fmt.Println()
+	testrom.Summary(os.Stdout, results)
+	if !passed {
+		os.Exit(1)
+	}
+}
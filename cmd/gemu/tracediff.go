@@ -0,0 +1,164 @@
+/*
+		gemu - the gameboy emulator
+				<3 m0x
+	    __________________________
+	   |                          |
+	   | .----------------------. |
+	   | |  .----------------.  | |
+	   | |  |                |  | |
+	   | |))|                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  '----------------'  | |
+	   | |__GAME BOY____________/ |
+	   |          ________        |
+	   |    .    (Nintendo)       |
+	   |  _| |_   """"""""   .-.  |
+	   |-[_   _]-       .-. (   ) |
+	   |   |_|         (   ) '-'  |
+	   |    '           '-'   A   |
+	   |                 B        |
+	   |          ___   ___       |
+	   |         (___) (___)  ,., |
+	   |        select start ;:;: |
+	   |                    ,;:;' /
+	   |                   ,:;:'.'
+	   '-----------------------`
+*/
+package main
+
+import (
+	"flag"
+	"fmt"
+	"gemu/pkg/gb"
+	"gemu/pkg/logger"
+	"gemu/pkg/trace"
+	"os"
+)
+
+// traceDiffMain compares a headless run against a reference trace, "gemu trace-diff rom.gb reference.log"
+func traceDiffMain(args []string) {
+	fs := flag.NewFlagSet("trace-diff", flag.ExitOnError)
+	logLevels := fs.String("log", "", "log levels, see gemu -help")
+	lenient := fs.Bool("lenient", false, "skip over unused opcodes instead of locking up the CPU")
+	keep := fs.Int("n", 10, "number of instructions to show before the divergence")
+	skipBoot := fs.Bool("skip-boot", false, "start in the state the boot ROM leaves behind instead of running it")
+	boot := fs.Bool("boot", false, "compare the boot ROM too, for references that start at 0000")
+	limit := fs.Uint64("max", 0, "stop after this many instructions, 0 for no limit")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: gemu trace-diff [flags] rom.gb reference.log[.gz]\n\n"+
+			"Runs the ROM without a display, comparing every instruction against a Gameboy-Doctor format trace\n"+
+			"and stopping at the first difference.\n\nflags:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	if err := logger.Parse(*logLevels); err != nil {
+		fmt.Println("[!] invalid -log flag - " + err.Error())
+		os.Exit(2)
+	}
+
+	gemu := &gb.GameBoy{Lenient: *lenient}
+	if err := gemu.Init(nil); err != nil {
+		fmt.Println("[!] gemu init failed - " + err.Error())
+		os.Exit(1)
+	}
+	if err := gemu.LoadROM(fs.Arg(0)); err != nil {
+		fmt.Println("[!] failed to load ROM - " + err.Error())
+		os.Exit(1)
+	}
+	if *skipBoot {
+		gemu.SkipBootROM()
+	}
+
+	differ, err := trace.OpenDiffer(fs.Arg(1), *keep)
+	if err != nil {
+		fmt.Println("[!] failed to open reference trace - " + err.Error())
+		os.Exit(1)
+	}
+	differ.Filter.BootROM = *boot
+	differ.Attach(gemu)
+
+	// Run until the differ has seen enough, or emulation fails. A locked up CPU never runs
+	// another instruction, so that's as far as the traces can be compared.
+	var steps uint64
+	var runErr error
+	for !differ.Stopped() && (*limit == 0 || steps < *limit) {
+		runErr = gemu.Step()
+		if gemu.Locked() {
+			differ.Lockup(gemu.CPU().Registers().PC)
+			runErr = nil
+			break
+		}
+		if runErr != nil {
+			break
+		}
+		steps++
+	}
+
+	// os.Exit skips deferred calls, so the traces are closed before exiting
+	code := report(differ, steps, runErr)
+	if err := differ.Close(); err != nil {
+		fmt.Println("[!] failed to close the traces - " + err.Error())
+	}
+	if code != 0 {
+		os.Exit(code)
+	}
+}
+
+// report prints how the run compared to the reference, returning the exit code
+func report(differ *trace.Differ, steps uint64, runErr error) int {
+	switch {
+	case differ.Err() != nil:
+		fmt.Println("[!] failed to read reference trace - " + differ.Err().Error())
+		return 1
+
+	case differ.Diverged() != nil:
+		d := differ.Diverged()
+		fmt.Printf("diverged at line %d of the reference\n\n", d.Line)
+		printHistory(differ.History())
+		fmt.Printf("  reference: %s\n", d.Want)
+		fmt.Printf("  gemu:      %s\n\n", d.Got)
+		for _, f := range d.Fields {
+			fmt.Printf("  %-5s want %-12s got %s\n", f.Name, f.Want, f.Got)
+		}
+		if op, name, ok := d.Opcode(); ok {
+			fmt.Printf("\nafter executing 0x%02X {%s}\n", op, name)
+		} else if d.HasPrevious {
+			fmt.Printf("\nafter executing an opcode gemu doesn't implement\n")
+		} else {
+			fmt.Printf("\nthe first instruction differs, so gemu started in a different state - try -skip-boot or -boot\n")
+		}
+		return 1
+
+	case runErr != nil:
+		fmt.Printf("emulation stopped at line %d of the reference (%s fault) - %s\n\n", differ.Lines(), gb.Classify(runErr), runErr)
+		printHistory(differ.History())
+		return 1
+
+	case differ.Finished():
+		fmt.Printf("traces match, %d lines compared\n", differ.Lines())
+
+	default:
+		fmt.Printf("stopped after %d instructions, traces match so far (%d lines compared)\n", steps, differ.Lines())
+	}
+	return 0
+}
+
+// printHistory prints the last instructions before a divergence or error
+func printHistory(history []string) {
+	if len(history) == 0 {
+		return
+	}
+	fmt.Println("previous instructions:")
+	for _, line := range history {
+		fmt.Printf("             %s\n", line)
+	}
+}
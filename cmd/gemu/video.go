@@ -0,0 +1,130 @@
+/*
+		gemu - the gameboy emulator
+				<3 m0x
+	    __________________________
+	   |                          |
+	   | .----------------------. |
+	   | |  .----------------.  | |
+	   | |  |                |  | |
+	   | |))|                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  '----------------'  | |
+	   | |__GAME BOY____________/ |
+	   |          ________        |
+	   |    .    (Nintendo)       |
+	   |  _| |_   """"""""   .-.  |
+	   |-[_   _]-       .-. (   ) |
+	   |   |_|         (   ) '-'  |
+	   |    '           '-'   A   |
+	   |                 B        |
+	   |          ___   ___       |
+	   |         (___) (___)  ,., |
+	   |        select start ;:;: |
+	   |                    ,;:;' /
+	   |                   ,:;:'.'
+	   '-----------------------`
+*/
+package main
+
+import (
+	"flag"
+	"fmt"
+	"gemu/pkg/gb"
+	"gemu/pkg/ppu"
+	"gemu/pkg/video"
+)
+
+// videoFormats are the formats recordings can be made in, and the extension of their path
+var videoFormats = map[string]string{"gif": ".gif", "png": ""}
+
+// videoRecorder records video of a GameBoy. It hooks OnFrame, so it's only used on the emulation goroutine.
+type videoRecorder struct {
+	gemu   *gb.GameBoy
+	path   string // Where to record from power on, if anywhere
+	format string // Format of recordings started with the hotkey
+
+	writer  video.Writer
+	current string // The path being recorded to
+}
+
+// register adds the video flags to a flag set
+func (v *videoRecorder) register(fs *flag.FlagSet) {
+	fs.StringVar(&v.path, "record-video", "", "record video from power on, to an animated GIF if it ends in .gif or a directory of numbered PNGs otherwise")
+	fs.StringVar(&v.format, "video-format", "gif", "format of recordings started with V, gif or png (a directory of numbered PNGs)")
+}
+
+// attach hooks the recorder into a GameBoy, starting the recording asked for with -record-video
+func (v *videoRecorder) attach(gemu *gb.GameBoy, p ppu.Palettes) error {
+	if _, ok := videoFormats[v.format]; !ok {
+		return fmt.Errorf("invalid -video-format flag %q, it's gif or png", v.format)
+	}
+
+	v.gemu = gemu
+	next := gemu.OnFrame
+	gemu.OnFrame = func(frame *ppu.Frame) {
+		if next != nil {
+			next(frame)
+		}
+		if v.writer == nil {
+			return
+		}
+		if err := v.writer.WriteFrame(frame); err != nil {
+			fmt.Println("[!] failed to record video - " + err.Error())
+			v.stop()
+		}
+	}
+
+	if v.path == "" {
+		return nil
+	}
+	return v.start(v.path, p)
+}
+
+// start starts recording to path, coloured with p
+func (v *videoRecorder) start(path string, p ppu.Palettes) error {
+	w, err := video.Create(path, p)
+	if err != nil {
+		return fmt.Errorf("failed to start recording video - %w", err)
+	}
+	v.writer, v.current = w, path
+	fmt.Println("Recording video to " + path)
+	return nil
+}
+
+// stop finishes the recording, if there is one
+func (v *videoRecorder) stop() error {
+	if v.writer == nil {
+		return nil
+	}
+	w := v.writer
+	v.writer = nil
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to write video - %w", err)
+	}
+	fmt.Printf("Recorded %d frames of video to %s\n", w.Frames(), v.current)
+	return nil
+}
+
+// close finishes the recording on exit, if there is one
+func (v *videoRecorder) close() {
+	if err := v.stop(); err != nil {
+		fmt.Println("[!] " + err.Error())
+	}
+}
+
+// toggle starts a recording named after the ROM and the time, or stops the one going
+func (v *videoRecorder) toggle(p ppu.Palettes) error {
+	if v.writer != nil {
+		return v.stop()
+	}
+	return v.start(v.gemu.CaptureName()+videoFormats[v.format], p)
+}
+
+// recording returns if video is being recorded
+func (v *videoRecorder) recording() bool {
+	return v.writer != nil
+}
@@ -29,3 +29,73 @@
 	   '-----------------------`
 */
 package apu
+
+import "gemu/pkg/savestate"
+
+/*	https://gbdev.io/pandocs/Audio.html
+
+Sound is not emulated yet. The APU only keeps its frame sequencer running, which clocks the
+length counters, sweep and envelopes at 512 Hz, so there is something to build on.
+The sound registers (FF10-FF3F) live in regular memory for now.
+*/
+
+// cyclesPerStep is how many T-cycles there are between frame sequencer steps (512 Hz)
+const cyclesPerStep = 8192
+
+// APU is the Audio Processing Unit
+type APU struct {
+	// T-cycles until the next frame sequencer step, and the current step (0-7)
+	cycles int
+	step   uint8
+
+	// muted silences the output without affecting emulation, e.g. while not running at normal speed.
+	// There's no output to silence until sound is emulated.
+	muted bool
+}
+
+// Init resets the APU, keeping it muted if it was
+func (apu *APU) Init() {
+	*apu = APU{cycles: cyclesPerStep, muted: apu.muted}
+}
+
+// SetMuted mutes or unmutes the APU's output
+func (apu *APU) SetMuted(muted bool) {
+	apu.muted = muted
+}
+
+// Muted reports if the APU's output is muted
+func (apu *APU) Muted() bool {
+	return apu.muted
+}
+
+// Tick advances the APU by the given number of T-cycles
+func (apu *APU) Tick(cycles int) {
+	apu.cycles -= cycles
+	for apu.cycles <= 0 {
+		apu.cycles += cyclesPerStep
+		apu.step = (apu.step + 1) & 7
+
+		// TODO: Clock length counters (even steps), sweep (steps 2 and 6) and envelopes (step 7)
+	}
+}
+
+// state is the APU's section of a save state
+type state struct {
+	Cycles int32
+	Step   uint8
+}
+
+// SaveState writes the APU's state
+func (apu *APU) SaveState(e *savestate.Encoder) {
+	e.Section("APU ", state{Cycles: int32(apu.cycles), Step: apu.step})
+}
+
+// LoadState restores the APU's state
+func (apu *APU) LoadState(d *savestate.Decoder) error {
+	var s state
+	if err := d.Section("APU ", &s); err != nil {
+		return err
+	}
+	apu.cycles, apu.step = int(s.Cycles), s.Step
+	return nil
+}
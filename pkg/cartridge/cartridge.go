@@ -29,3 +29,219 @@
 	   '-----------------------`
 */
 package cartridge
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"gemu/pkg/logger"
+	"os"
+	"strings"
+)
+
+/*	https://gbdev.io/pandocs/The_Cartridge_Header.html
+
+Every cartridge ROM has a header at 0100-014F describing the game and the hardware on the cartridge.
+
+Addr		Description
+0100-0103	Entry point
+0104-0133	Nintendo logo
+0134-0143	Title, upper case ASCII padded with 0x00 (0143 is the CGB flag on newer cartridges)
+0147		Cartridge type, which MBC and extras are on the cartridge
+0148		ROM size, 32 KiB << n
+0149		RAM size
+014D		Header checksum
+*/
+
+// Header is the cartridge header
+type Header struct {
+	Title    string
+	Type     uint8 // Cartridge type, see Type.String
+	ROMBanks int   // Number of 16 KiB ROM banks
+	RAMSize  int   // Size of the external RAM in bytes
+	Checksum uint8 // Header checksum, as stored in the ROM
+	Valid    bool  // Does Checksum match the header?
+}
+
+// ramSizes maps the RAM size code in the header to a size in bytes
+var ramSizes = map[uint8]int{
+	0x00: 0,
+	0x01: 2 * 1024, // Unused, but some homebrew uses it
+	0x02: 8 * 1024,
+	0x03: 32 * 1024,
+	0x04: 128 * 1024,
+	0x05: 64 * 1024,
+}
+
+// typeNames maps the cartridge type in the header to a name
+var typeNames = map[uint8]string{
+	0x00: "ROM ONLY",
+	0x01: "MBC1",
+	0x02: "MBC1+RAM",
+	0x03: "MBC1+RAM+BATTERY",
+	0x05: "MBC2",
+	0x06: "MBC2+BATTERY",
+	0x08: "ROM+RAM",
+	0x09: "ROM+RAM+BATTERY",
+	0x0F: "MBC3+TIMER+BATTERY",
+	0x10: "MBC3+TIMER+RAM+BATTERY",
+	0x11: "MBC3",
+	0x12: "MBC3+RAM",
+	0x13: "MBC3+RAM+BATTERY",
+	0x19: "MBC5",
+	0x1A: "MBC5+RAM",
+	0x1B: "MBC5+RAM+BATTERY",
+	0x1C: "MBC5+RUMBLE",
+	0x1D: "MBC5+RUMBLE+RAM",
+	0x1E: "MBC5+RUMBLE+RAM+BATTERY",
+}
+
+// TypeName returns the name of the cartridge type in the header
+func (h Header) TypeName() string {
+	if name, ok := typeNames[h.Type]; ok {
+		return name
+	}
+	return fmt.Sprintf("UNKNOWN (0x%02X)", h.Type)
+}
+
+// ParseHeader parses the header of a cartridge ROM
+func ParseHeader(rom []byte) (Header, error) {
+	if len(rom) < 0x150 {
+		return Header{}, &FaultError{Reason: fmt.Sprintf("ROM is too small to have a header (%d bytes)", len(rom))}
+	}
+
+	h := Header{
+		Type:     rom[0x147],
+		ROMBanks: 2 << rom[0x148],
+		Checksum: rom[0x14D],
+	}
+
+	// The title is padded with zeros, and newer cartridges use the last byte as the CGB flag
+	title := rom[0x134:0x144]
+	if title[15]&0x80 != 0 {
+		title = title[:15]
+	}
+	h.Title = strings.TrimRight(string(title), "\x00")
+
+	size, ok := ramSizes[rom[0x149]]
+	if !ok {
+		return h, &FaultError{Reason: fmt.Sprintf("unknown RAM size 0x%02X", rom[0x149])}
+	}
+	h.RAMSize = size
+
+	// The boot ROM checks this, and locks up if it doesn't match
+	sum := uint8(0)
+	for _, b := range rom[0x134:0x14D] {
+		sum = sum - b - 1
+	}
+	h.Valid = sum == h.Checksum
+
+	return h, nil
+}
+
+// Cartridge is a game pak, the ROM and the hardware that came with it
+type Cartridge struct {
+	Header Header
+
+	// The Memory Bank Controller, which maps the ROM and RAM banks into the address space
+	mbc mbc
+
+	// The MBC3's Real Time Clock, nil on cartridges without one
+	rtc *RTC
+
+	// SHA-1 of the ROM image, as it was loaded
+	sum [sha1.Size]byte
+}
+
+// Load loads a cartridge ROM from a file
+func Load(path string) (*Cartridge, error) {
+	rom, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return New(rom)
+}
+
+// New creates a cartridge from a ROM image
+func New(rom []byte) (*Cartridge, error) {
+	h, err := ParseHeader(rom)
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha1.Sum(rom)
+
+	if !h.Valid {
+		logger.Cartridge.Warnf("Header checksum doesn't match, real hardware would refuse to boot this ROM")
+	}
+	if len(rom) != h.ROMBanks*0x4000 {
+		logger.Cartridge.Warnf("ROM is %d bytes, but the header says it should be %d bytes", len(rom), h.ROMBanks*0x4000)
+	}
+
+	// Pad the ROM out to a whole number of banks, so banking never has to worry about running off the end
+	if len(rom)%0x4000 != 0 || len(rom) < 0x8000 {
+		size := (len(rom) + 0x3FFF) &^ 0x3FFF
+		if size < 0x8000 {
+			size = 0x8000
+		}
+		padded := make([]byte, size)
+		copy(padded, rom)
+		for i := len(rom); i < size; i++ {
+			padded[i] = 0xFF
+		}
+		rom = padded
+	}
+
+	ram := make([]byte, h.RAMSize)
+
+	c := &Cartridge{Header: h, sum: sum}
+	switch h.Type {
+	case 0x00, 0x08, 0x09:
+		c.mbc = &romOnly{rom: rom, ram: ram}
+	case 0x01, 0x02, 0x03:
+		c.mbc = &mbc1{rom: rom, ram: ram, romBank: 1}
+	case 0x0F, 0x10:
+		c.rtc = new(RTC)
+		c.mbc = &mbc3{rom: rom, ram: ram, romBank: 1, rtc: c.rtc}
+	case 0x11, 0x12, 0x13:
+		c.mbc = &mbc3{rom: rom, ram: ram, romBank: 1}
+	case 0x19, 0x1A, 0x1B, 0x1C, 0x1D, 0x1E:
+		c.mbc = &mbc5{rom: rom, ram: ram, romBank: 1}
+	default:
+		return nil, &FaultError{Reason: "unsupported cartridge type " + h.TypeName()}
+	}
+
+	logger.Cartridge.Infof("Loaded %q - %s, %d ROM banks, %d bytes of RAM", h.Title, h.TypeName(), h.ROMBanks, h.RAMSize)
+	return c, nil
+}
+
+// Read reads from the cartridge, either ROM (0000-7FFF) or external RAM (A000-BFFF)
+func (c *Cartridge) Read(addr uint16) uint8 {
+	return c.mbc.Read(addr)
+}
+
+// Write writes to the cartridge. Writes to ROM set the MBC registers, writes to A000-BFFF go to external RAM.
+func (c *Cartridge) Write(addr uint16, value uint8) {
+	c.mbc.Write(addr, value)
+}
+
+// ROMBank returns the ROM bank currently mapped at 4000-7FFF
+func (c *Cartridge) ROMBank() int {
+	return c.mbc.ROMBank()
+}
+
+// Tick advances the cartridge's clock, if it has one, by the given number of T-cycles
+func (c *Cartridge) Tick(cycles int) {
+	if c.rtc != nil {
+		c.rtc.Tick(cycles)
+	}
+}
+
+// RTC returns the cartridge's Real Time Clock, or nil if it doesn't have one
+func (c *Cartridge) RTC() *RTC {
+	return c.rtc
+}
+
+// SHA1 returns the SHA-1 of the ROM image, which identifies the game more reliably than the header
+func (c *Cartridge) SHA1() [sha1.Size]byte {
+	return c.sum
+}
@@ -0,0 +1,47 @@
+/*
+		gemu - the gameboy emulator
+				<3 m0x
+	    __________________________
+	   |                          |
+	   | .----------------------. |
+	   | |  .----------------.  | |
+	   | |  |                |  | |
+	   | |))|                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  '----------------'  | |
+	   | |__GAME BOY____________/ |
+	   |          ________        |
+	   |    .    (Nintendo)       |
+	   |  _| |_   """"""""   .-.  |
+	   |-[_   _]-       .-. (   ) |
+	   |   |_|         (   ) '-'  |
+	   |    '           '-'   A   |
+	   |                 B        |
+	   |          ___   ___       |
+	   |         (___) (___)  ,., |
+	   |        select start ;:;: |
+	   |                    ,;:;' /
+	   |                   ,:;:'.'
+	   '-----------------------`
+*/
+package cartridge
+
+import "fmt"
+
+// FaultError is returned when a cartridge can't be used, either because the ROM image is bad
+// or because the game used the cartridge hardware in a way it doesn't support.
+type FaultError struct {
+	Reason string // What went wrong
+	Addr   uint16 // The address being accessed, if the fault happened on the bus
+}
+
+func (e *FaultError) Error() string {
+	if e.Addr != 0 {
+		return fmt.Sprintf("cartridge fault at 0x%04X: %s", e.Addr, e.Reason)
+	}
+	return fmt.Sprintf("cartridge fault: %s", e.Reason)
+}
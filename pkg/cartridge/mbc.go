@@ -0,0 +1,359 @@
+/*
+		gemu - the gameboy emulator
+				<3 m0x
+	    __________________________
+	   |                          |
+	   | .----------------------. |
+	   | |  .----------------.  | |
+	   | |  |                |  | |
+	   | |))|                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  '----------------'  | |
+	   | |__GAME BOY____________/ |
+	   |          ________        |
+	   |    .    (Nintendo)       |
+	   |  _| |_   """"""""   .-.  |
+	   |-[_   _]-       .-. (   ) |
+	   |   |_|         (   ) '-'  |
+	   |    '           '-'   A   |
+	   |                 B        |
+	   |          ___   ___       |
+	   |         (___) (___)  ,., |
+	   |        select start ;:;: |
+	   |                    ,;:;' /
+	   |                   ,:;:'.'
+	   '-----------------------`
+*/
+package cartridge
+
+import "gemu/pkg/savestate"
+
+/*	https://gbdev.io/pandocs/MBCs.html
+
+Memory Bank Controllers (MBCs) extend the address space by switching banks of ROM into 4000-7FFF
+and banks of external RAM into A000-BFFF. The game switches banks by writing to the ROM area,
+which the MBC intercepts.
+*/
+
+// mbc is a Memory Bank Controller
+type mbc interface {
+	Read(addr uint16) uint8
+	Write(addr uint16, value uint8)
+	ROMBank() int
+
+	// RAM returns the external RAM, empty if there is none
+	RAM() []byte
+
+	// SaveState and LoadState save and restore the banking registers
+	SaveState(e *savestate.Encoder)
+	LoadState(d *savestate.Decoder) error
+}
+
+// romOffset returns the offset of a bank in the ROM, wrapping around like the unconnected address lines do
+func romOffset(rom []byte, bank int) int {
+	return (bank * 0x4000) % len(rom)
+}
+
+// ramOffset returns the offset of an address within a bank of external RAM, wrapping around if the RAM is smaller
+func ramOffset(ram []byte, bank int, addr uint16) int {
+	return (bank*0x2000 + int(addr-0xA000)) % len(ram)
+}
+
+// romOnly is a cartridge without an MBC, 32 KiB of ROM and optionally up to 8 KiB of RAM
+type romOnly struct {
+	rom, ram []byte
+}
+
+func (m *romOnly) Read(addr uint16) uint8 {
+	if addr < 0x8000 {
+		return m.rom[addr]
+	}
+	if len(m.ram) == 0 {
+		return 0xFF
+	}
+	return m.ram[ramOffset(m.ram, 0, addr)]
+}
+
+func (m *romOnly) Write(addr uint16, value uint8) {
+	if addr >= 0xA000 && len(m.ram) > 0 {
+		m.ram[ramOffset(m.ram, 0, addr)] = value
+	}
+}
+
+func (m *romOnly) ROMBank() int {
+	return 1
+}
+
+func (m *romOnly) RAM() []byte {
+	return m.ram
+}
+
+func (m *romOnly) SaveState(e *savestate.Encoder) {}
+
+func (m *romOnly) LoadState(d *savestate.Decoder) error {
+	return nil
+}
+
+// mbc1 is the MBC1 - up to 2 MiB ROM and 32 KiB RAM
+//
+// Addr		Register
+// 0000-1FFF	RAM enable, 0x0A in the lower nibble enables
+// 2000-3FFF	ROM bank, lower 5 bits (0 is treated as 1)
+// 4000-5FFF	RAM bank, or upper 2 bits of the ROM bank
+// 6000-7FFF	Banking mode, 1 applies the 4000-5FFF register to 0000-3FFF and RAM too
+type mbc1 struct {
+	rom, ram []byte
+
+	ramEnabled bool
+	romBank    uint8 // Lower 5 bits of the ROM bank
+	upper      uint8 // 2 bit register, RAM bank or upper ROM bank bits
+	mode       uint8
+}
+
+func (m *mbc1) Read(addr uint16) uint8 {
+	switch {
+	case addr < 0x4000:
+		bank := 0
+		if m.mode == 1 {
+			bank = int(m.upper) << 5
+		}
+		return m.rom[romOffset(m.rom, bank)+int(addr)]
+	case addr < 0x8000:
+		return m.rom[romOffset(m.rom, m.ROMBank())+int(addr-0x4000)]
+	}
+
+	if !m.ramEnabled || len(m.ram) == 0 {
+		return 0xFF
+	}
+	return m.ram[ramOffset(m.ram, m.ramBank(), addr)]
+}
+
+func (m *mbc1) Write(addr uint16, value uint8) {
+	switch {
+	case addr < 0x2000:
+		m.ramEnabled = value&0x0F == 0x0A
+	case addr < 0x4000:
+		m.romBank = value & 0x1F
+		if m.romBank == 0 {
+			m.romBank = 1
+		}
+	case addr < 0x6000:
+		m.upper = value & 0x03
+	case addr < 0x8000:
+		m.mode = value & 0x01
+	default:
+		if m.ramEnabled && len(m.ram) > 0 {
+			m.ram[ramOffset(m.ram, m.ramBank(), addr)] = value
+		}
+	}
+}
+
+func (m *mbc1) ROMBank() int {
+	return (int(m.upper)<<5 | int(m.romBank)) % (len(m.rom) / 0x4000)
+}
+
+func (m *mbc1) ramBank() int {
+	if m.mode == 1 {
+		return int(m.upper)
+	}
+	return 0
+}
+
+func (m *mbc1) RAM() []byte {
+	return m.ram
+}
+
+// mbc1State is the MBC1's section of a save state
+type mbc1State struct {
+	RAMEnabled           bool
+	ROMBank, Upper, Mode uint8
+}
+
+func (m *mbc1) SaveState(e *savestate.Encoder) {
+	e.Section("MBC ", mbc1State{m.ramEnabled, m.romBank, m.upper, m.mode})
+}
+
+func (m *mbc1) LoadState(d *savestate.Decoder) error {
+	var s mbc1State
+	if err := d.Section("MBC ", &s); err != nil {
+		return err
+	}
+	m.ramEnabled, m.romBank, m.upper, m.mode = s.RAMEnabled, s.ROMBank, s.Upper, s.Mode
+	return nil
+}
+
+// mbc3 is the MBC3 - up to 2 MiB ROM, 32 KiB RAM and a Real Time Clock
+//
+// Addr		Register
+// 0000-1FFF	RAM and RTC enable, 0x0A in the lower nibble enables
+// 2000-3FFF	ROM bank, 7 bits (0 is treated as 1)
+// 4000-5FFF	RAM bank (00-03), or RTC register (08-0C)
+// 6000-7FFF	Latch clock data, writing 0x00 then 0x01 latches the RTC registers
+type mbc3 struct {
+	rom, ram []byte
+
+	// The Real Time Clock, nil on cartridges without one
+	rtc *RTC
+
+	ramEnabled bool
+	romBank    uint8
+	ramBank    uint8 // RAM bank, or RTC register when 0x08-0x0C
+}
+
+func (m *mbc3) Read(addr uint16) uint8 {
+	switch {
+	case addr < 0x4000:
+		return m.rom[addr]
+	case addr < 0x8000:
+		return m.rom[romOffset(m.rom, m.ROMBank())+int(addr-0x4000)]
+	}
+
+	if !m.ramEnabled {
+		return 0xFF
+	}
+	if m.ramBank >= 0x08 {
+		if m.rtc == nil {
+			return 0xFF
+		}
+		return m.rtc.read(m.ramBank)
+	}
+	if len(m.ram) == 0 {
+		return 0xFF
+	}
+	return m.ram[ramOffset(m.ram, int(m.ramBank), addr)]
+}
+
+func (m *mbc3) Write(addr uint16, value uint8) {
+	switch {
+	case addr < 0x2000:
+		m.ramEnabled = value&0x0F == 0x0A
+	case addr < 0x4000:
+		m.romBank = value & 0x7F
+		if m.romBank == 0 {
+			m.romBank = 1
+		}
+	case addr < 0x6000:
+		m.ramBank = value
+	case addr < 0x8000:
+		if m.rtc != nil {
+			m.rtc.writeLatch(value)
+		}
+	default:
+		switch {
+		case !m.ramEnabled:
+		case m.ramBank >= 0x08:
+			if m.rtc != nil {
+				m.rtc.write(m.ramBank, value)
+			}
+		case len(m.ram) > 0:
+			m.ram[ramOffset(m.ram, int(m.ramBank), addr)] = value
+		}
+	}
+}
+
+func (m *mbc3) ROMBank() int {
+	return int(m.romBank) % (len(m.rom) / 0x4000)
+}
+
+func (m *mbc3) RAM() []byte {
+	return m.ram
+}
+
+// mbc3State is the MBC3's section of a save state
+type mbc3State struct {
+	RAMEnabled       bool
+	ROMBank, RAMBank uint8
+}
+
+func (m *mbc3) SaveState(e *savestate.Encoder) {
+	e.Section("MBC ", mbc3State{m.ramEnabled, m.romBank, m.ramBank})
+}
+
+func (m *mbc3) LoadState(d *savestate.Decoder) error {
+	var s mbc3State
+	if err := d.Section("MBC ", &s); err != nil {
+		return err
+	}
+	m.ramEnabled, m.romBank, m.ramBank = s.RAMEnabled, s.ROMBank, s.RAMBank
+	return nil
+}
+
+// mbc5 is the MBC5 - up to 8 MiB ROM and 128 KiB RAM
+//
+// Addr		Register
+// 0000-1FFF	RAM enable, 0x0A in the lower nibble enables
+// 2000-2FFF	ROM bank, lower 8 bits (0 really is bank 0 on the MBC5)
+// 3000-3FFF	ROM bank, bit 8
+// 4000-5FFF	RAM bank (00-0F)
+type mbc5 struct {
+	rom, ram []byte
+
+	ramEnabled bool
+	romBank    uint16
+	ramBank    uint8
+}
+
+func (m *mbc5) Read(addr uint16) uint8 {
+	switch {
+	case addr < 0x4000:
+		return m.rom[addr]
+	case addr < 0x8000:
+		return m.rom[romOffset(m.rom, m.ROMBank())+int(addr-0x4000)]
+	}
+
+	if !m.ramEnabled || len(m.ram) == 0 {
+		return 0xFF
+	}
+	return m.ram[ramOffset(m.ram, int(m.ramBank), addr)]
+}
+
+func (m *mbc5) Write(addr uint16, value uint8) {
+	switch {
+	case addr < 0x2000:
+		m.ramEnabled = value&0x0F == 0x0A
+	case addr < 0x3000:
+		m.romBank = m.romBank&0x100 | uint16(value)
+	case addr < 0x4000:
+		m.romBank = m.romBank&0xFF | uint16(value&0x01)<<8
+	case addr < 0x6000:
+		m.ramBank = value & 0x0F
+	case addr < 0x8000:
+		// Nothing here
+	default:
+		if m.ramEnabled && len(m.ram) > 0 {
+			m.ram[ramOffset(m.ram, int(m.ramBank), addr)] = value
+		}
+	}
+}
+
+func (m *mbc5) ROMBank() int {
+	return int(m.romBank) % (len(m.rom) / 0x4000)
+}
+
+func (m *mbc5) RAM() []byte {
+	return m.ram
+}
+
+// mbc5State is the MBC5's section of a save state
+type mbc5State struct {
+	RAMEnabled bool
+	ROMBank    uint16
+	RAMBank    uint8
+}
+
+func (m *mbc5) SaveState(e *savestate.Encoder) {
+	e.Section("MBC ", mbc5State{m.ramEnabled, m.romBank, m.ramBank})
+}
+
+func (m *mbc5) LoadState(d *savestate.Decoder) error {
+	var s mbc5State
+	if err := d.Section("MBC ", &s); err != nil {
+		return err
+	}
+	m.ramEnabled, m.romBank, m.ramBank = s.RAMEnabled, s.ROMBank, s.RAMBank
+	return nil
+}
@@ -0,0 +1,199 @@
+/*
+		gemu - the gameboy emulator
+				<3 m0x
+	    __________________________
+	   |                          |
+	   | .----------------------. |
+	   | |  .----------------.  | |
+	   | |  |                |  | |
+	   | |))|                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  '----------------'  | |
+	   | |__GAME BOY____________/ |
+	   |          ________        |
+	   |    .    (Nintendo)       |
+	   |  _| |_   """"""""   .-.  |
+	   |-[_   _]-       .-. (   ) |
+	   |   |_|         (   ) '-'  |
+	   |    '           '-'   A   |
+	   |                 B        |
+	   |          ___   ___       |
+	   |         (___) (___)  ,., |
+	   |        select start ;:;: |
+	   |                    ,;:;' /
+	   |                   ,:;:'.'
+	   '-----------------------`
+*/
+package cartridge
+
+import (
+	"gemu/pkg/savestate"
+	"time"
+)
+
+/*	https://gbdev.io/pandocs/MBC3.html#the-clock-counter-registers
+
+The MBC3's Real Time Clock counts seconds, minutes, hours and days from its own crystal.
+It's driven by emulated time here rather than the wall clock, so emulation stays deterministic,
+and starts from whatever time it's set to with Set.
+
+Reg	Description
+08	Seconds (0-59)
+09	Minutes (0-59)
+0A	Hours (0-23)
+0B	Lower 8 bits of the day counter
+0C	Bit 0 - bit 8 of the day counter, bit 6 - halt, bit 7 - day counter carry
+
+The registers the game reads are a copy of the clock, taken by writing 0x00 then 0x01 to 6000-7FFF.
+*/
+
+// cyclesPerSecond is how many T-cycles the RTC sees per second
+const cyclesPerSecond = 4194304
+
+// rtcRegisters are the clock counter registers
+type rtcRegisters struct {
+	S, M, H, DL, DH uint8
+}
+
+// RTC is the MBC3's Real Time Clock
+type RTC struct {
+	live, latched rtcRegisters
+
+	// T-cycles into the current second
+	cycles int32
+
+	// The last value written to the latch register, latching happens on 0x00 then 0x01
+	latch uint8
+}
+
+// Set sets the clock to the given time since day 0, clearing the halt and carry flags
+func (r *RTC) Set(t time.Duration) {
+	secs := int64(t / time.Second)
+	days := secs / 86400
+	r.live = rtcRegisters{
+		S:  uint8(secs % 60),
+		M:  uint8(secs / 60 % 60),
+		H:  uint8(secs / 3600 % 24),
+		DL: uint8(days),
+		DH: uint8(days>>8) & 0x01,
+	}
+	r.latched = r.live
+	r.cycles = 0
+}
+
+// Time returns the clock's time since day 0
+func (r *RTC) Time() time.Duration {
+	days := int64(r.live.DH&0x01)<<8 | int64(r.live.DL)
+	secs := days*86400 + int64(r.live.H)*3600 + int64(r.live.M)*60 + int64(r.live.S)
+	return time.Duration(secs) * time.Second
+}
+
+// Tick advances the clock by the given number of T-cycles, unless it's halted
+func (r *RTC) Tick(cycles int) {
+	if r.live.DH&0x40 != 0 {
+		return
+	}
+
+	r.cycles += int32(cycles)
+	for r.cycles >= cyclesPerSecond {
+		r.cycles -= cyclesPerSecond
+		r.tick()
+	}
+}
+
+// tick advances the clock by a second. The counters are 6 and 5 bits wide, so a game that writes
+// an out of range value sees it count up to the top of the register and wrap to 0 without a carry.
+func (r *RTC) tick() {
+	l := &r.live
+	if l.S = (l.S + 1) & 0x3F; l.S != 60 {
+		return
+	}
+	l.S = 0
+	if l.M = (l.M + 1) & 0x3F; l.M != 60 {
+		return
+	}
+	l.M = 0
+	if l.H = (l.H + 1) & 0x1F; l.H != 24 {
+		return
+	}
+	l.H = 0
+	if l.DL++; l.DL != 0 {
+		return
+	}
+	if l.DH&0x01 == 0 {
+		l.DH |= 0x01
+		return
+	}
+	// The day counter overflowed, it wraps to 0 and sets the carry until the game clears it
+	l.DH = l.DH&^0x01 | 0x80
+}
+
+// writeLatch latches the clock into the registers the game reads, on a write of 0x00 then 0x01
+func (r *RTC) writeLatch(value uint8) {
+	if r.latch == 0x00 && value == 0x01 {
+		r.latched = r.live
+	}
+	r.latch = value
+}
+
+// read reads a latched clock register, reg is 0x08-0x0C
+func (r *RTC) read(reg uint8) uint8 {
+	switch reg {
+	case 0x08:
+		return r.latched.S
+	case 0x09:
+		return r.latched.M
+	case 0x0A:
+		return r.latched.H
+	case 0x0B:
+		return r.latched.DL
+	case 0x0C:
+		// Unused bits read as 1
+		return r.latched.DH | 0x3E
+	}
+	return 0xFF
+}
+
+// write writes a live clock register, reg is 0x08-0x0C
+func (r *RTC) write(reg uint8, value uint8) {
+	switch reg {
+	case 0x08:
+		// Writing the seconds resets the sub-second counter
+		r.live.S = value & 0x3F
+		r.cycles = 0
+	case 0x09:
+		r.live.M = value & 0x3F
+	case 0x0A:
+		r.live.H = value & 0x1F
+	case 0x0B:
+		r.live.DL = value
+	case 0x0C:
+		r.live.DH = value & 0xC1
+	}
+}
+
+// rtcState is the RTC's section of a save state
+type rtcState struct {
+	Live, Latched rtcRegisters
+	Cycles        int32
+	Latch         uint8
+}
+
+// SaveState writes the RTC's state
+func (r *RTC) SaveState(e *savestate.Encoder) {
+	e.Section("RTC ", rtcState{r.live, r.latched, r.cycles, r.latch})
+}
+
+// LoadState restores the RTC's state
+func (r *RTC) LoadState(d *savestate.Decoder) error {
+	var s rtcState
+	if err := d.Section("RTC ", &s); err != nil {
+		return err
+	}
+	r.live, r.latched, r.cycles, r.latch = s.Live, s.Latched, s.Cycles, s.Latch
+	return nil
+}
@@ -0,0 +1,102 @@
+/*
+		gemu - the gameboy emulator
+				<3 m0x
+	    __________________________
+	   |                          |
+	   | .----------------------. |
+	   | |  .----------------.  | |
+	   | |  |                |  | |
+	   | |))|                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  '----------------'  | |
+	   | |__GAME BOY____________/ |
+	   |          ________        |
+	   |    .    (Nintendo)       |
+	   |  _| |_   """"""""   .-.  |
+	   |-[_   _]-       .-. (   ) |
+	   |   |_|         (   ) '-'  |
+	   |    '           '-'   A   |
+	   |                 B        |
+	   |          ___   ___       |
+	   |         (___) (___)  ,., |
+	   |        select start ;:;: |
+	   |                    ,;:;' /
+	   |                   ,:;:'.'
+	   '-----------------------`
+*/
+package cartridge
+
+import (
+	"fmt"
+	"gemu/pkg/savestate"
+)
+
+// identity is the cartridge's section of a save state, so a state isn't loaded into a different game
+type identity struct {
+	Title    [16]byte
+	Type     uint8
+	Checksum uint8
+	ROMBanks int32
+}
+
+func (c *Cartridge) identity() identity {
+	id := identity{Type: c.Header.Type, Checksum: c.Header.Checksum, ROMBanks: int32(c.Header.ROMBanks)}
+	copy(id.Title[:], c.Header.Title)
+	return id
+}
+
+// SaveState writes the cartridge's banking registers and external RAM
+func (c *Cartridge) SaveState(e *savestate.Encoder) {
+	e.Section("CART", c.identity())
+	e.Section("CRAM", c.mbc.RAM())
+	c.mbc.SaveState(e)
+	if c.rtc != nil {
+		c.rtc.SaveState(e)
+	}
+}
+
+// Check returns an error if the state was saved from a different cartridge
+func (c *Cartridge) Check(d *savestate.Decoder) error {
+	var id identity
+	if err := d.Section("CART", &id); err != nil {
+		return err
+	}
+	if id != c.identity() {
+		return fmt.Errorf("save state is for %q, not %q", trimTitle(id.Title), c.Header.Title)
+	}
+	if ram, _ := d.Bytes("CRAM"); len(ram) != len(c.mbc.RAM()) {
+		return fmt.Errorf("save state has %d bytes of cartridge RAM, expected %d", len(ram), len(c.mbc.RAM()))
+	}
+	return nil
+}
+
+// LoadState restores the cartridge's banking registers and external RAM
+func (c *Cartridge) LoadState(d *savestate.Decoder) error {
+	if err := c.Check(d); err != nil {
+		return err
+	}
+	if err := d.Section("CRAM", c.mbc.RAM()); err != nil {
+		return err
+	}
+	if err := c.mbc.LoadState(d); err != nil {
+		return err
+	}
+
+	// States from before the RTC was emulated don't have it
+	if c.rtc != nil && d.Has("RTC ") {
+		return c.rtc.LoadState(d)
+	}
+	return nil
+}
+
+func trimTitle(title [16]byte) string {
+	n := 0
+	for n < len(title) && title[n] != 0 {
+		n++
+	}
+	return string(title[:n])
+}
@@ -0,0 +1,46 @@
+/*
+		gemu - the gameboy emulator
+				<3 m0x
+	    __________________________
+	   |                          |
+	   | .----------------------. |
+	   | |  .----------------.  | |
+	   | |  |                |  | |
+	   | |))|                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  '----------------'  | |
+	   | |__GAME BOY____________/ |
+	   |          ________        |
+	   |    .    (Nintendo)       |
+	   |  _| |_   """"""""   .-.  |
+	   |-[_   _]-       .-. (   ) |
+	   |   |_|         (   ) '-'  |
+	   |    '           '-'   A   |
+	   |                 B        |
+	   |          ___   ___       |
+	   |         (___) (___)  ,., |
+	   |        select start ;:;: |
+	   |                    ,;:;' /
+	   |                   ,:;:'.'
+	   '-----------------------`
+*/
+package cpu
+
+// Bus is everything the CPU is connected to. Memory accesses and the passing of time both go through it,
+// so the rest of the system runs in lockstep with the CPU. The MMU is the GameBoy's bus.
+type Bus interface {
+	Read(addr uint16) uint8
+	Write(addr uint16, value uint8)
+
+	// Tick advances the rest of the system by the given number of T-cycles
+	Tick(cycles int)
+}
+
+// faulter is a bus that records invalid accesses, which are returned by Step once the instruction is done
+type faulter interface {
+	Fault() error
+}
@@ -31,10 +31,7 @@
 package cpu
 
 import (
-	"fmt"
-	"gemu/pkg/boot"
-	"gemu/pkg/mmu"
-	"time"
+	"gemu/pkg/logger"
 )
 
 // The DMG-01 had a Sharp LR35902 CPU (speculated to be a SM83 core), which is a hybrid of the Z80 and the 8080
@@ -43,23 +40,47 @@ type CPU struct {
 	// Registers
 	reg Registers
 
-	// Memory
-	mem *mmu.MMU
+	// Memory, and the rest of the system that's ticked along with it
+	mem Bus
 
 	// Clock Cycles
 	// Interesting discussion - https://www.reddit.com/r/EmuDev/comments/4o2t6k/how_do_you_emulate_specific_cpu_speeds/
-	cycles    uint32
-	maxCycles uint32
+	// The CPU doesn't keep time itself, the GameBoy paces emulation using this count of T-cycles since power on.
+	cycles uint64
 
 	// Halt flag
 	halted bool
+
+	// Locked is set once an unused opcode is executed, which hard locks the CPU.
+	// Only a power cycle gets it going again.
+	locked bool
+
+	// OnAccess, if set, is called after every memory access the CPU makes, including opcode fetches.
+	// Debuggers use it for watchpoints.
+	OnAccess func(addr uint16, value uint8, write bool)
+
+	// OnInstruction, if set, is called with the registers before each instruction is fetched.
+	// Tracers use it to log the state the instruction runs in.
+	OnInstruction func(reg Registers)
+
+	// Lenient makes the unused opcodes skip over themselves with a warning instead of locking up
+	// the CPU, which is handy when debugging homebrew.
+	Lenient bool
+
+	// Error raised by the instruction currently executing, returned by Step
+	err error
 }
 
-// Initializes the CPU
-func (cpu *CPU) Init(mmu *mmu.MMU) {
-	cpu.mem = mmu
-	cpu.mem.Init()
+// New creates a CPU connected to a bus, in its power on state.
+// Mapping the boot ROM is up to whatever owns the bus, the CPU just starts executing from 0000.
+func New(bus Bus) *CPU {
+	cpu := &CPU{mem: bus}
+	cpu.Reset()
+	return cpu
+}
 
+// Reset puts the CPU back in its power on state, as if it had been power cycled. The hooks are kept.
+func (cpu *CPU) Reset() {
 	/*
 		Set initial registers to 0x00 - The DMG-01 power up sequence, per PanDocs, is:
 		https://gbdev.io/pandocs/Power_Up_Sequence.html
@@ -74,7 +95,7 @@ func (cpu *CPU) Init(mmu *mmu.MMU) {
 		PC = 0x0100
 		SP = 0xFFFE
 
-		This should be what the boot ROM does.
+		This should be what the boot ROM does. See PostBoot.
 	*/
 	cpu.reg.A = 0x00
 	cpu.reg.F = 0x00
@@ -87,65 +108,136 @@ func (cpu *CPU) Init(mmu *mmu.MMU) {
 	cpu.reg.PC = 0x0000
 	cpu.reg.SP = 0x0000
 
-	// 4.194304 MHz was the highest freq the DMG could run at.
-	cpu.maxCycles = 4194304
-
+	cpu.cycles = 0
 	cpu.halted = false
-
-	// Load the boot ROM into memory
-	fmt.Println("Loading boot ROM...")
-	cpu.LoadBootROM()
-
-	// Loads cartridge into memory
-	// TODO: Implement cartridge loading
+	cpu.locked = false
+	cpu.err = nil
 }
 
-// Loads the boot ROM into memory
-func (cpu *CPU) LoadBootROM() {
-	for addr, val := range boot.BootRom {
-		//fmt.Printf("Loading boot ROM at 0x%X with value 0x%X\n", addr, val)
-		cpu.mem.Write(uint16(addr), val)
-	}
-	cpu.reg.PC = 0x0000
-}
+// PostBoot is the state the DMG boot ROM leaves the registers in, at the cartridge entry point
+var PostBoot = Registers{A: 0x01, F: 0xB0, B: 0x00, C: 0x13, D: 0x00, E: 0xD8, H: 0x01, L: 0x4D, SP: 0xFFFE, PC: 0x0100}
 
 // Step the CPU for a single instruction - Fetch, decode, execute
+// The rest of the system is ticked as the instruction accesses memory, so it runs in lockstep with the CPU.
 func (cpu *CPU) Step() error {
-	// Is the CPU halted?
-	if !cpu.halted {
+	start := cpu.cycles
+
+	// Is the CPU halted or locked up?
+	if !cpu.halted && !cpu.locked {
+		if cpu.OnInstruction != nil {
+			cpu.OnInstruction(cpu.reg)
+		}
+
+		pc := cpu.reg.PC
 		op := cpu.fetch()
 		instruction, valid := opcodes[op]
 		if !valid {
 			cpu.reg.PC++
-			return fmt.Errorf("opcode not implmented: 0x%x", op)
+			return &UnimplementedOpcodeError{Opcode: op, PC: pc}
+		}
+
+		if logger.CPU.Enabled(logger.Trace) {
+			logger.CPU.Tracef("PC = 0x%04X, op = 0x%02X {%s}", pc, op, instruction.name)
 		}
 
 		// Execute opcode
-		cpu.cycles += instruction.cycles
+		cpu.err = nil
 		instruction.execute(cpu)
 
+		// Burn the remaining M-cycles the instruction spent off the bus
+		for cpu.cycles-start < uint64(instruction.cycles) {
+			cpu.tick()
+		}
+		if taken := cpu.cycles - start; taken != uint64(instruction.cycles) {
+			logger.Timing.Warnf("0x%02X {%s} took %d T-cycles, expected %d", op, instruction.name, taken, instruction.cycles)
+		}
+
 		// Bits 0-3 of the Flag register are always zero, as they are unused.
 		cpu.reg.F &^= FlagUnused
+
+		if cpu.err != nil {
+			return cpu.err
+		}
+		if f, ok := cpu.mem.(faulter); ok {
+			if err := f.Fault(); err != nil {
+				return err
+			}
+		}
 	} else {
 		// NOP NOP bby ~
-		cpu.cycles += 4
+		// Locked up CPUs still get clocked, so the rest of the hardware keeps running.
+		cpu.tick()
 	}
 
-	// Check CPU Cycles
-	// TODO: Will need to check that this timeing is accurate...
-	//fmt.Printf("Cycles: %d, Max: %d\n", cpu.cycles, cpu.maxCycles)
-	if cpu.cycles > cpu.maxCycles {
-		fmt.Println("[CPU Timer]: Enforcing 4.194304 Mhz")
-		time.Sleep(1 * time.Second)
-		cpu.cycles = 0
+	return nil
+}
+
+// Cycles returns the number of T-cycles the CPU has run since power on
+func (cpu *CPU) Cycles() uint64 {
+	return cpu.cycles
+}
+
+// tick advances the CPU and the rest of the system by a single M-cycle (4 T-cycles)
+func (cpu *CPU) tick() {
+	cpu.mem.Tick(4)
+	cpu.cycles += 4
+}
+
+// read reads from memory, taking an M-cycle
+func (cpu *CPU) read(addr uint16) uint8 {
+	cpu.tick()
+	value := cpu.mem.Read(addr)
+	if cpu.OnAccess != nil {
+		cpu.OnAccess(addr, value, false)
 	}
+	return value
+}
 
-	return nil
+// write writes to memory, taking an M-cycle
+func (cpu *CPU) write(addr uint16, value uint8) {
+	cpu.tick()
+	cpu.mem.Write(addr, value)
+	if cpu.OnAccess != nil {
+		cpu.OnAccess(addr, value, true)
+	}
+}
+
+// Registers returns a copy of the CPU registers
+func (cpu *CPU) Registers() Registers {
+	return cpu.reg
+}
+
+// SetRegisters sets all of the CPU registers, for tests and debuggers
+func (cpu *CPU) SetRegisters(reg Registers) {
+	cpu.reg = reg
+}
+
+// Halted reports if the CPU is halted, waiting for an interrupt
+func (cpu *CPU) Halted() bool {
+	return cpu.halted
+}
+
+// Locked reports if the CPU has locked up after executing an unused opcode
+func (cpu *CPU) Locked() bool {
+	return cpu.locked
+}
+
+// illegal handles the unused Z80 opcodes, which lock up the Gameboy.
+// PC is left pointing at the opcode, as the CPU never gets past it.
+func (cpu *CPU) illegal(op uint8, name string) {
+	if cpu.Lenient {
+		logger.Unimplemented.Warnf("Z80 UNUSED 0x%02X {%s} at 0x%04X would lock up the Gameboy, skipping", op, name, cpu.reg.PC)
+		cpu.reg.PC++
+		return
+	}
+
+	cpu.locked = true
+	cpu.err = &IllegalOpcodeError{Opcode: op, Name: name, PC: cpu.reg.PC}
 }
 
 // Fetches the next opcode from memory
 func (cpu *CPU) fetch() uint8 {
-	op := cpu.mem.Read(cpu.reg.PC)
+	op := cpu.read(cpu.reg.PC)
 	//fmt.Printf("PC = 0x%X, op = 0x%X\n", cpu.reg.PC, op)
 	return op
 }
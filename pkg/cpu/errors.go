@@ -0,0 +1,60 @@
+/*
+		gemu - the gameboy emulator
+				<3 m0x
+	    __________________________
+	   |                          |
+	   | .----------------------. |
+	   | |  .----------------.  | |
+	   | |  |                |  | |
+	   | |))|                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  '----------------'  | |
+	   | |__GAME BOY____________/ |
+	   |          ________        |
+	   |    .    (Nintendo)       |
+	   |  _| |_   """"""""   .-.  |
+	   |-[_   _]-       .-. (   ) |
+	   |   |_|         (   ) '-'  |
+	   |    '           '-'   A   |
+	   |                 B        |
+	   |          ___   ___       |
+	   |         (___) (___)  ,., |
+	   |        select start ;:;: |
+	   |                    ,;:;' /
+	   |                   ,:;:'.'
+	   '-----------------------`
+*/
+package cpu
+
+import "fmt"
+
+// UnimplementedOpcodeError is returned by Step when the CPU fetches an opcode that gemu does not implement yet.
+// Real hardware would have executed it, so this is an emulator bug rather than a ROM bug.
+type UnimplementedOpcodeError struct {
+	Opcode uint8  // The opcode that was fetched
+	CB     bool   // Is Opcode a CB prefixed opcode?
+	PC     uint16 // Address of the instruction
+}
+
+func (e *UnimplementedOpcodeError) Error() string {
+	if e.CB {
+		return fmt.Sprintf("opcode not implemented: 0xCB 0x%02X at 0x%04X", e.Opcode, e.PC)
+	}
+	return fmt.Sprintf("opcode not implemented: 0x%02X at 0x%04X", e.Opcode, e.PC)
+}
+
+// IllegalOpcodeError is returned by Step when the CPU executes one of the unused Z80 opcodes and locks up.
+// This happens on real hardware too, so this is a ROM bug rather than an emulator bug.
+type IllegalOpcodeError struct {
+	Opcode uint8  // The opcode that was fetched
+	Name   string // The Z80 mnemonic of the opcode
+	PC     uint16 // Address of the instruction
+}
+
+func (e *IllegalOpcodeError) Error() string {
+	return fmt.Sprintf("illegal opcode 0x%02X {%s} at 0x%04X, the Gameboy would lock up", e.Opcode, e.Name, e.PC)
+}
@@ -30,10 +30,13 @@
 */
 package cpu
 
-import "fmt"
-
 // https://gbdev.io/gb-opcodes/optables/
 // https://gbdev.io/gb-opcodes/Opcodes.json
+// https://gekkio.fi/files/gb-docs/gbctr.pdf (M-cycle by M-cycle breakdown of each instruction)
+//
+// Opcodes access memory through cpu.read and cpu.write, which take an M-cycle each and tick the rest of the system,
+// so accesses land on the same M-cycle they would on real hardware. M-cycles spent without touching the bus
+// are ticked with cpu.tick where they happen, or padded at the end of the instruction by Step.
 // https://www.pastraiser.com/cpu/gameboy/gameboy_opcodes.html
 // http://marc.rawer.de/Gameboy/Docs/GBCPUman.pdf
 
@@ -56,7 +59,7 @@ var opcodes = map[uint8]struct {
 	// Bytes: 1
 	// Flags: - - - -
 	0x02: {name: "LD (BC), A", cycles: 8, execute: func(cpu *CPU) {
-		cpu.mem.Write(cpu.BC(), cpu.reg.A)
+		cpu.write(cpu.BC(), cpu.reg.A)
 		cpu.reg.PC++
 	}},
 
@@ -65,7 +68,7 @@ var opcodes = map[uint8]struct {
 	// Bytes: 2
 	// Flags: - - - -
 	0x06: {name: "LD B, d8", cycles: 8, execute: func(cpu *CPU) {
-		cpu.reg.B = cpu.mem.Read(cpu.reg.PC + 1)
+		cpu.reg.B = cpu.read(cpu.reg.PC + 1)
 		cpu.reg.PC += 2
 	}},
 
@@ -74,7 +77,7 @@ var opcodes = map[uint8]struct {
 	// Bytes: 1
 	// Flags: - - - -
 	0x0A: {name: "LD A, (BC)", cycles: 8, execute: func(cpu *CPU) {
-		cpu.reg.A = cpu.mem.Read(cpu.BC())
+		cpu.reg.A = cpu.read(cpu.BC())
 		cpu.reg.PC++
 	}},
 
@@ -83,7 +86,7 @@ var opcodes = map[uint8]struct {
 	// Bytes: 2
 	// Flags: - - - -
 	0x0E: {name: "LD C, d8", cycles: 8, execute: func(cpu *CPU) {
-		cpu.reg.C = cpu.mem.Read(cpu.reg.PC + 1)
+		cpu.reg.C = cpu.read(cpu.reg.PC + 1)
 		cpu.reg.PC += 2
 	}},
 
@@ -92,7 +95,7 @@ var opcodes = map[uint8]struct {
 	// Bytes: 1
 	// Flags: - - - -
 	0x12: {name: "LD (DE), A", cycles: 8, execute: func(cpu *CPU) {
-		cpu.mem.Write(cpu.DE(), cpu.reg.A)
+		cpu.write(cpu.DE(), cpu.reg.A)
 		cpu.reg.PC++
 	}},
 
@@ -101,7 +104,7 @@ var opcodes = map[uint8]struct {
 	// Bytes: 2
 	// Flags: - - - -
 	0x16: {name: "LD D, d8", cycles: 8, execute: func(cpu *CPU) {
-		cpu.reg.D = cpu.mem.Read(cpu.reg.PC + 1)
+		cpu.reg.D = cpu.read(cpu.reg.PC + 1)
 		cpu.reg.PC += 2
 	}},
 
@@ -110,7 +113,7 @@ var opcodes = map[uint8]struct {
 	// Bytes: 1
 	// Flags: - - - -
 	0x1A: {name: "LD A, (DE)", cycles: 8, execute: func(cpu *CPU) {
-		cpu.reg.A = cpu.mem.Read(cpu.DE())
+		cpu.reg.A = cpu.read(cpu.DE())
 		cpu.reg.PC++
 	}},
 
@@ -119,7 +122,7 @@ var opcodes = map[uint8]struct {
 	// Bytes: 2
 	// Flags: - - - -
 	0x1E: {name: "LD E, d8", cycles: 8, execute: func(cpu *CPU) {
-		cpu.reg.E = cpu.mem.Read(cpu.reg.PC + 1)
+		cpu.reg.E = cpu.read(cpu.reg.PC + 1)
 		cpu.reg.PC += 2
 	}},
 
@@ -128,7 +131,7 @@ var opcodes = map[uint8]struct {
 	// Bytes: 1
 	// Flags: - - - -
 	0x22: {name: "LD (HL+), A", cycles: 8, execute: func(cpu *CPU) {
-		cpu.mem.Write(cpu.HL(), cpu.reg.A)
+		cpu.write(cpu.HL(), cpu.reg.A)
 		cpu.reg.PC++
 	}},
 
@@ -137,7 +140,7 @@ var opcodes = map[uint8]struct {
 	// Bytes: 2
 	// Flags: - - - -
 	0x26: {name: "LD H, d8", cycles: 8, execute: func(cpu *CPU) {
-		cpu.reg.H = cpu.mem.Read(cpu.reg.PC + 1)
+		cpu.reg.H = cpu.read(cpu.reg.PC + 1)
 		cpu.reg.PC += 2
 	}},
 
@@ -146,7 +149,7 @@ var opcodes = map[uint8]struct {
 	// Bytes: 1
 	// Flags: - - - -
 	0x2A: {name: "LD A, (HL+)", cycles: 8, execute: func(cpu *CPU) {
-		cpu.reg.A = cpu.mem.Read(cpu.HL())
+		cpu.reg.A = cpu.read(cpu.HL())
 		cpu.reg.PC++
 	}},
 
@@ -155,7 +158,7 @@ var opcodes = map[uint8]struct {
 	// Bytes: 2
 	// Flags: - - - -
 	0x2E: {name: "LD L, d8", cycles: 8, execute: func(cpu *CPU) {
-		cpu.reg.L = cpu.mem.Read(cpu.reg.PC + 1)
+		cpu.reg.L = cpu.read(cpu.reg.PC + 1)
 		cpu.reg.PC += 2
 	}},
 
@@ -164,16 +167,16 @@ var opcodes = map[uint8]struct {
 	// Bytes: 1
 	// Flags: - - - -
 	0x32: {name: "LD (HL-), A", cycles: 8, execute: func(cpu *CPU) {
-		cpu.mem.Write(cpu.HL(), cpu.reg.A)
+		cpu.write(cpu.HL(), cpu.reg.A)
 		cpu.reg.PC++
 	}},
 
 	// 0x36 - LD (HL),d8 - Load immediate 8-bit value into memory at address HL
-	// Cycles: 8
+	// Cycles: 12
 	// Bytes: 2
 	// Flags: - - - -
-	0x36: {name: "LD (HL), d8", cycles: 8, execute: func(cpu *CPU) {
-		cpu.mem.Write(cpu.HL(), cpu.mem.Read(cpu.reg.PC+1))
+	0x36: {name: "LD (HL), d8", cycles: 12, execute: func(cpu *CPU) {
+		cpu.write(cpu.HL(), cpu.read(cpu.reg.PC+1))
 		cpu.reg.PC += 2
 	}},
 
@@ -182,7 +185,7 @@ var opcodes = map[uint8]struct {
 	// Bytes: 1
 	// Flags: - - - -
 	0x3A: {name: "LD A, (HL-)", cycles: 8, execute: func(cpu *CPU) {
-		cpu.reg.A = cpu.mem.Read(cpu.HL())
+		cpu.reg.A = cpu.read(cpu.HL())
 		cpu.reg.PC++
 	}},
 
@@ -191,7 +194,7 @@ var opcodes = map[uint8]struct {
 	// Bytes: 2
 	// Flags: - - - -
 	0x3E: {name: "LD A, d8", cycles: 8, execute: func(cpu *CPU) {
-		cpu.reg.A = cpu.mem.Read(cpu.reg.PC + 1)
+		cpu.reg.A = cpu.read(cpu.reg.PC + 1)
 		cpu.reg.PC += 2
 	}},
 
@@ -254,7 +257,7 @@ var opcodes = map[uint8]struct {
 	// Bytes: 1
 	// Flags: - - - -
 	0x46: {name: "LD B, (HL)", cycles: 8, execute: func(cpu *CPU) {
-		cpu.reg.B = cpu.mem.Read(cpu.HL())
+		cpu.reg.B = cpu.read(cpu.HL())
 		cpu.reg.PC++
 	}},
 
@@ -326,7 +329,7 @@ var opcodes = map[uint8]struct {
 	// Bytes: 1
 	// Flags: - - - -
 	0x4E: {name: "LD C, (HL)", cycles: 8, execute: func(cpu *CPU) {
-		cpu.reg.C = cpu.mem.Read(cpu.HL())
+		cpu.reg.C = cpu.read(cpu.HL())
 		cpu.reg.PC++
 	}},
 
@@ -398,7 +401,7 @@ var opcodes = map[uint8]struct {
 	// Bytes: 1
 	// Flags: - - - -
 	0x56: {name: "LD D, (HL)", cycles: 8, execute: func(cpu *CPU) {
-		cpu.reg.D = cpu.mem.Read(cpu.HL())
+		cpu.reg.D = cpu.read(cpu.HL())
 		cpu.reg.PC++
 	}},
 
@@ -470,7 +473,7 @@ var opcodes = map[uint8]struct {
 	// Bytes: 1
 	// Flags: - - - -
 	0x5E: {name: "LD E, (HL)", cycles: 8, execute: func(cpu *CPU) {
-		cpu.reg.E = cpu.mem.Read(cpu.HL())
+		cpu.reg.E = cpu.read(cpu.HL())
 		cpu.reg.PC++
 	}},
 
@@ -542,7 +545,7 @@ var opcodes = map[uint8]struct {
 	// Bytes: 1
 	// Flags: - - - -
 	0x66: {name: "LD H, (HL)", cycles: 8, execute: func(cpu *CPU) {
-		cpu.reg.H = cpu.mem.Read(cpu.HL())
+		cpu.reg.H = cpu.read(cpu.HL())
 		cpu.reg.PC++
 	}},
 
@@ -614,7 +617,7 @@ var opcodes = map[uint8]struct {
 	// Bytes: 1
 	// Flags: - - - -
 	0x6E: {name: "LD L, (HL)", cycles: 8, execute: func(cpu *CPU) {
-		cpu.reg.L = cpu.mem.Read(cpu.HL())
+		cpu.reg.L = cpu.read(cpu.HL())
 		cpu.reg.PC++
 	}},
 
@@ -632,7 +635,7 @@ var opcodes = map[uint8]struct {
 	// Bytes: 1
 	// Flags: - - - -
 	0x70: {name: "LD (HL), B", cycles: 8, execute: func(cpu *CPU) {
-		cpu.mem.Write(cpu.HL(), cpu.reg.B)
+		cpu.write(cpu.HL(), cpu.reg.B)
 		cpu.reg.PC++
 	}},
 
@@ -641,7 +644,7 @@ var opcodes = map[uint8]struct {
 	// Bytes: 1
 	// Flags: - - - -
 	0x71: {name: "LD (HL), C", cycles: 8, execute: func(cpu *CPU) {
-		cpu.mem.Write(cpu.HL(), cpu.reg.C)
+		cpu.write(cpu.HL(), cpu.reg.C)
 		cpu.reg.PC++
 	}},
 
@@ -650,7 +653,7 @@ var opcodes = map[uint8]struct {
 	// Bytes: 1
 	// Flags: - - - -
 	0x72: {name: "LD (HL), D", cycles: 8, execute: func(cpu *CPU) {
-		cpu.mem.Write(cpu.HL(), cpu.reg.D)
+		cpu.write(cpu.HL(), cpu.reg.D)
 		cpu.reg.PC++
 	}},
 
@@ -659,7 +662,7 @@ var opcodes = map[uint8]struct {
 	// Bytes: 1
 	// Flags: - - - -
 	0x73: {name: "LD (HL), E", cycles: 8, execute: func(cpu *CPU) {
-		cpu.mem.Write(cpu.HL(), cpu.reg.E)
+		cpu.write(cpu.HL(), cpu.reg.E)
 		cpu.reg.PC++
 	}},
 
@@ -668,7 +671,7 @@ var opcodes = map[uint8]struct {
 	// Bytes: 1
 	// Flags: - - - -
 	0x74: {name: "LD (HL), H", cycles: 8, execute: func(cpu *CPU) {
-		cpu.mem.Write(cpu.HL(), cpu.reg.H)
+		cpu.write(cpu.HL(), cpu.reg.H)
 		cpu.reg.PC++
 	}},
 
@@ -677,7 +680,7 @@ var opcodes = map[uint8]struct {
 	// Bytes: 1
 	// Flags: - - - -
 	0x75: {name: "LD (HL), L", cycles: 8, execute: func(cpu *CPU) {
-		cpu.mem.Write(cpu.HL(), cpu.reg.L)
+		cpu.write(cpu.HL(), cpu.reg.L)
 		cpu.reg.PC++
 	}},
 
@@ -686,7 +689,7 @@ var opcodes = map[uint8]struct {
 	// Bytes: 1
 	// Flags: - - - -
 	0x77: {name: "LD (HL), A", cycles: 8, execute: func(cpu *CPU) {
-		cpu.mem.Write(cpu.HL(), cpu.reg.A)
+		cpu.write(cpu.HL(), cpu.reg.A)
 		cpu.reg.PC++
 	}},
 
@@ -749,7 +752,7 @@ var opcodes = map[uint8]struct {
 	// Bytes: 1
 	// Flags: - - - -
 	0x7E: {name: "LD A, (HL)", cycles: 8, execute: func(cpu *CPU) {
-		cpu.reg.A = cpu.mem.Read(cpu.HL())
+		cpu.reg.A = cpu.read(cpu.HL())
 		cpu.reg.PC++
 	}},
 
@@ -767,7 +770,7 @@ var opcodes = map[uint8]struct {
 	// Bytes: 2
 	// Flags: - - - -
 	0xE0: {name: "LDH (n), A", cycles: 12, execute: func(cpu *CPU) {
-		cpu.mem.Write(0xFF00+uint16(cpu.mem.Read(cpu.reg.PC+1)), cpu.reg.A)
+		cpu.write(0xFF00+uint16(cpu.read(cpu.reg.PC+1)), cpu.reg.A)
 		cpu.reg.PC += 2
 	}},
 
@@ -776,7 +779,7 @@ var opcodes = map[uint8]struct {
 	// Bytes: 1
 	// Flags: - - - -
 	0xE2: {name: "LD (C), A", cycles: 8, execute: func(cpu *CPU) {
-		cpu.mem.Write(0xFF00+uint16(cpu.reg.C), cpu.reg.A)
+		cpu.write(0xFF00+uint16(cpu.reg.C), cpu.reg.A)
 		cpu.reg.PC += 2
 	}},
 
@@ -785,8 +788,8 @@ var opcodes = map[uint8]struct {
 	// Bytes: 3
 	// Flags: - - - -
 	0xEA: {name: "LD (a16), A", cycles: 16, execute: func(cpu *CPU) {
-		addr := uint16(cpu.mem.Read(cpu.reg.PC+1)) | uint16(cpu.mem.Read(cpu.reg.PC+2))<<8
-		cpu.mem.Write(addr, cpu.reg.A)
+		addr := uint16(cpu.read(cpu.reg.PC+1)) | uint16(cpu.read(cpu.reg.PC+2))<<8
+		cpu.write(addr, cpu.reg.A)
 		cpu.reg.PC += 3
 	}},
 
@@ -795,8 +798,8 @@ var opcodes = map[uint8]struct {
 	// Bytes: 2
 	// Flags: - - - -
 	0xF0: {name: "LDH A, (a8)", cycles: 12, execute: func(cpu *CPU) {
-		addr := uint16(cpu.mem.Read(cpu.reg.PC + 1))
-		cpu.reg.A = cpu.mem.Read(0xFF00 + addr)
+		addr := uint16(cpu.read(cpu.reg.PC + 1))
+		cpu.reg.A = cpu.read(0xFF00 + addr)
 		cpu.reg.PC += 2
 	}},
 
@@ -805,7 +808,7 @@ var opcodes = map[uint8]struct {
 	// Bytes: 1
 	// Flags: - - - -
 	0xF2: {name: "LD A, (C)", cycles: 8, execute: func(cpu *CPU) {
-		cpu.reg.A = cpu.mem.Read(0xFF00 + uint16(cpu.reg.C))
+		cpu.reg.A = cpu.read(0xFF00 + uint16(cpu.reg.C))
 		cpu.reg.PC += 2
 	}},
 
@@ -814,8 +817,8 @@ var opcodes = map[uint8]struct {
 	// Bytes: 3
 	// Flags: - - - -
 	0xFA: {name: "LD A, (a16)", cycles: 16, execute: func(cpu *CPU) {
-		addr := uint16(cpu.mem.Read(cpu.reg.PC+1)) | uint16(cpu.mem.Read(cpu.reg.PC+2))<<8
-		cpu.reg.A = cpu.mem.Read(addr)
+		addr := uint16(cpu.read(cpu.reg.PC+1)) | uint16(cpu.read(cpu.reg.PC+2))<<8
+		cpu.reg.A = cpu.read(addr)
 		cpu.reg.PC += 3
 	}},
 
@@ -832,7 +835,7 @@ var opcodes = map[uint8]struct {
 	// Bytes: 3
 	// Flags: - - - -
 	0x01: {name: "LD BC, d16", cycles: 12, execute: func(cpu *CPU) {
-		cpu.SetBC(uint16(cpu.mem.Read(cpu.reg.PC+1)) | uint16(cpu.mem.Read(cpu.reg.PC+2))<<8)
+		cpu.SetBC(uint16(cpu.read(cpu.reg.PC+1)) | uint16(cpu.read(cpu.reg.PC+2))<<8)
 		cpu.reg.PC += 3
 	}},
 
@@ -841,7 +844,7 @@ var opcodes = map[uint8]struct {
 	// Bytes: 3
 	// Flags: - - - -
 	0x11: {name: "LD DE, d16", cycles: 12, execute: func(cpu *CPU) {
-		cpu.SetDE(uint16(cpu.mem.Read(cpu.reg.PC+1)) | uint16(cpu.mem.Read(cpu.reg.PC+2))<<8)
+		cpu.SetDE(uint16(cpu.read(cpu.reg.PC+1)) | uint16(cpu.read(cpu.reg.PC+2))<<8)
 		cpu.reg.PC += 3
 	}},
 
@@ -850,7 +853,7 @@ var opcodes = map[uint8]struct {
 	// Bytes: 3
 	// Flags: - - - -
 	0x21: {name: "LD HL, d16", cycles: 12, execute: func(cpu *CPU) {
-		cpu.SetHL(uint16(cpu.mem.Read(cpu.reg.PC+1)) | uint16(cpu.mem.Read(cpu.reg.PC+2))<<8)
+		cpu.SetHL(uint16(cpu.read(cpu.reg.PC+1)) | uint16(cpu.read(cpu.reg.PC+2))<<8)
 		cpu.reg.PC += 3
 	}},
 
@@ -859,7 +862,7 @@ var opcodes = map[uint8]struct {
 	// Bytes: 3
 	// Flags: - - - -
 	0x31: {name: "LD SP, d16", cycles: 12, execute: func(cpu *CPU) {
-		cpu.reg.SP = uint16(cpu.mem.Read(cpu.reg.PC+1)) | uint16(cpu.mem.Read(cpu.reg.PC+2))<<8
+		cpu.reg.SP = uint16(cpu.read(cpu.reg.PC+1)) | uint16(cpu.read(cpu.reg.PC+2))<<8
 		cpu.reg.PC += 3
 	}},
 
@@ -909,6 +912,7 @@ var opcodes = map[uint8]struct {
 	// Bytes: 1
 	// Flags: - - - -
 	0xC5: {name: "PUSH BC", cycles: 16, execute: func(cpu *CPU) {
+		cpu.tick() // SP is decremented before the first write
 		cpu.stackPush(cpu.reg.B)
 		cpu.stackPush(cpu.reg.C)
 		cpu.reg.PC++
@@ -919,6 +923,7 @@ var opcodes = map[uint8]struct {
 	// Bytes: 1
 	// Flags: - - - -
 	0xD5: {name: "PUSH DE", cycles: 16, execute: func(cpu *CPU) {
+		cpu.tick() // SP is decremented before the first write
 		cpu.stackPush(cpu.reg.D)
 		cpu.stackPush(cpu.reg.E)
 		cpu.reg.PC++
@@ -929,6 +934,7 @@ var opcodes = map[uint8]struct {
 	// Bytes: 1
 	// Flags: - - - -
 	0xE5: {name: "PUSH HL", cycles: 16, execute: func(cpu *CPU) {
+		cpu.tick() // SP is decremented before the first write
 		cpu.stackPush(cpu.reg.H)
 		cpu.stackPush(cpu.reg.L)
 		cpu.reg.PC++
@@ -939,6 +945,7 @@ var opcodes = map[uint8]struct {
 	// Bytes: 1
 	// Flags: - - - -
 	0xF5: {name: "PUSH AF", cycles: 16, execute: func(cpu *CPU) {
+		cpu.tick() // SP is decremented before the first write
 		cpu.stackPush(cpu.reg.A)
 		cpu.stackPush(cpu.reg.F)
 		cpu.reg.PC++
@@ -949,9 +956,9 @@ var opcodes = map[uint8]struct {
 	// Bytes: 3
 	// Flags: - - - -
 	0x08: {name: "LD (a16), SP", cycles: 20, execute: func(cpu *CPU) {
-		addr := uint16(cpu.mem.Read(cpu.reg.PC+1)) + uint16(cpu.mem.Read(cpu.reg.PC+2))<<8
-		cpu.mem.Write(addr, uint8(cpu.reg.SP&0xFF))
-		cpu.mem.Write(addr+1, uint8(cpu.reg.SP>>8)&0xFF)
+		addr := uint16(cpu.read(cpu.reg.PC+1)) + uint16(cpu.read(cpu.reg.PC+2))<<8
+		cpu.write(addr, uint8(cpu.reg.SP&0xFF))
+		cpu.write(addr+1, uint8(cpu.reg.SP>>8)&0xFF)
 		cpu.reg.PC += 3
 	}},
 
@@ -964,7 +971,7 @@ var opcodes = map[uint8]struct {
 		cpu.reg.F &= ^FlagMask
 
 		// Get the value of r8 and SP+r8
-		r8 := uint8(cpu.mem.Read(cpu.reg.PC + 1))
+		r8 := uint8(cpu.read(cpu.reg.PC + 1))
 		spr8 := cpu.reg.SP + uint16(r8)
 
 		// Set flags
@@ -1059,9 +1066,9 @@ var opcodes = map[uint8]struct {
 	// Flags: Z 0 H -
 	0x34: {name: "INC (HL)", cycles: 12, execute: func(cpu *CPU) {
 		addr := cpu.HL()
-		val := cpu.mem.Read(addr)
+		val := cpu.read(addr)
 		cpu.Inc8(&val)
-		cpu.mem.Write(addr, val)
+		cpu.write(addr, val)
 		cpu.reg.PC++
 	}},
 
@@ -1134,9 +1141,9 @@ var opcodes = map[uint8]struct {
 	// Flags: Z 1 H -
 	0x35: {name: "DEC (HL)", cycles: 12, execute: func(cpu *CPU) {
 		addr := cpu.HL()
-		val := cpu.mem.Read(addr)
+		val := cpu.read(addr)
 		cpu.Dec8(&val)
-		cpu.mem.Write(addr, val)
+		cpu.write(addr, val)
 		cpu.reg.PC++
 	}},
 
@@ -1208,7 +1215,7 @@ var opcodes = map[uint8]struct {
 	// Bytes: 1
 	// Flags: Z 0 H C
 	0x86: {name: "ADD A, (HL)", cycles: 8, execute: func(cpu *CPU) {
-		cpu.Add8(&cpu.reg.A, cpu.mem.Read(cpu.HL()), false)
+		cpu.Add8(&cpu.reg.A, cpu.read(cpu.HL()), false)
 		cpu.reg.PC++
 	}},
 
@@ -1280,7 +1287,7 @@ var opcodes = map[uint8]struct {
 	// Bytes: 1
 	// Flags: Z 0 H C
 	0x8E: {name: "ADC A, (HL)", cycles: 8, execute: func(cpu *CPU) {
-		cpu.Add8(&cpu.reg.A, cpu.mem.Read(cpu.HL()), true)
+		cpu.Add8(&cpu.reg.A, cpu.read(cpu.HL()), true)
 		cpu.reg.PC++
 	}},
 
@@ -1384,7 +1391,7 @@ var opcodes = map[uint8]struct {
 	// Bytes: 1
 	// Flags: Z 1 H C
 	0x96: {name: "SUB (HL)", cycles: 8, execute: func(cpu *CPU) {
-		cpu.Sub8(&cpu.reg.A, cpu.mem.Read(cpu.HL()), false)
+		cpu.Sub8(&cpu.reg.A, cpu.read(cpu.HL()), false)
 		cpu.reg.PC++
 	}},
 
@@ -1456,7 +1463,7 @@ var opcodes = map[uint8]struct {
 	// Bytes: 1
 	// Flags: Z 1 H C
 	0x9E: {name: "SBC A, (HL)", cycles: 8, execute: func(cpu *CPU) {
-		cpu.Sub8(&cpu.reg.A, cpu.mem.Read(cpu.HL()), true)
+		cpu.Sub8(&cpu.reg.A, cpu.read(cpu.HL()), true)
 		cpu.reg.PC++
 	}},
 
@@ -1576,7 +1583,7 @@ var opcodes = map[uint8]struct {
 	// Bytes: 1
 	// Flags: Z 0 1 0
 	0xA6: {name: "AND (HL)", cycles: 8, execute: func(cpu *CPU) {
-		cpu.And8(&cpu.reg.A, cpu.mem.Read(cpu.HL()))
+		cpu.And8(&cpu.reg.A, cpu.read(cpu.HL()))
 		cpu.reg.PC++
 	}},
 
@@ -1614,15 +1621,13 @@ var opcodes = map[uint8]struct {
 	/////////////////////////////////////////////////////////////////////////////////////////
 
 	// 0xCB - PREFIX CB - CB prefix operation
-	// Cycles: 4
-	// Bytes: 1
+	// Cycles: 8 (fetching the CB opcode takes an extra M-cycle, 16 for (HL) operations)
+	// Bytes: 2
 	// Flags: - - - -
-	0xCB: {name: "PREFIX CB", cycles: 4, execute: func(cpu *CPU) {
+	0xCB: {name: "PREFIX CB", cycles: 8, execute: func(cpu *CPU) {
 		//TODO: Need to actually implement the CB Prefix operations lol
-		fmt.Println("[Warning] CB prefix operations not implemented")
-		cb_op := cpu.mem.Read(cpu.reg.PC + 1)
-		fmt.Printf("\t |--> cb_op: %02X\n", cb_op)
-		fmt.Printf("\t |--> This may cause Z80 UNUSED warnings\n")
+		cb_op := cpu.read(cpu.reg.PC + 1)
+		cpu.err = &UnimplementedOpcodeError{Opcode: cb_op, CB: true, PC: cpu.reg.PC}
 		cpu.reg.PC += 2
 	}},
 
@@ -1656,55 +1661,50 @@ var opcodes = map[uint8]struct {
 
 	/////////////////////////////////////////////////////////////////////////////////////////
 	// Unused Z80 opcodes
-	// TODO: Should I make the emulator crash or just ignore?
 	//
 	// These opcodes were not implemented in the Gameboy CPU,
 	// and will cause the Gameboy to lock up when used.
+	// Instruction execution stops for good, while the rest of the hardware keeps running.
 	// https://gbdev.io/pandocs/CPU_Comparison_with_Z80.html
 	// https://clrhome.org/table/ (Z80 Opcode Set)
 	/////////////////////////////////////////////////////////////////////////////////////////
 	0xD3: {name: "OUT (a8), A", cycles: 4, execute: func(cpu *CPU) {
-		fmt.Println("[Z80 UNUSED] 0xD3 {OUT (a8), A} will cause the Gameboy to crash!")
-		cpu.reg.PC++
+		cpu.illegal(0xD3, "OUT (a8), A")
 	}},
 	0xDB: {name: "IN A, (a8)", cycles: 4, execute: func(cpu *CPU) {
-		fmt.Println("[Z80 UNUSED] 0xDB {IN A, (a8)} will cause the Gameboy to crash!")
-		cpu.reg.PC++
+		cpu.illegal(0xDB, "IN A, (a8)")
 	}},
 	0xDD: {name: "IX PREFIX", cycles: 4, execute: func(cpu *CPU) {
-		fmt.Println("[Z80 UNUSED] 0xDD {IX PREFIX} will cause the Gameboy to crash!")
-		cpu.reg.PC++
+		cpu.illegal(0xDD, "IX PREFIX")
 	}},
 	0xE3: {name: "EX (SP), HL", cycles: 4, execute: func(cpu *CPU) {
-		fmt.Println("[Z80 UNUSED] 0xE3 {EX (SP), HL} will cause the Gameboy to crash!")
-		cpu.reg.PC++
+		cpu.illegal(0xE3, "EX (SP), HL")
 	}},
 	0xE4: {name: "CALL PO, a16", cycles: 4, execute: func(cpu *CPU) {
-		fmt.Println("[Z80 UNUSED] 0xE4 {CALL PO, a16} will cause the Gameboy to crash!")
-		cpu.reg.PC++
+		cpu.illegal(0xE4, "CALL PO, a16")
 	}},
 	0xEB: {name: "EX DE, HL", cycles: 4, execute: func(cpu *CPU) {
-		fmt.Println("[Z80 UNUSED] 0xEB {EX DE, HL} will cause the Gameboy to crash!")
-		cpu.reg.PC++
+		cpu.illegal(0xEB, "EX DE, HL")
 	}},
 	0xEC: {name: "CALL PE, a16", cycles: 4, execute: func(cpu *CPU) {
-		fmt.Println("[Z80 UNUSED] 0xEC {CALL PE, a16} will cause the Gameboy to crash!")
-		cpu.reg.PC++
+		cpu.illegal(0xEC, "CALL PE, a16")
 	}},
 	0xED: {name: "MISC PREFIX (ED)", cycles: 4, execute: func(cpu *CPU) {
-		fmt.Println("[Z80 UNUSED] 0xED {MISC PREFIX (ED)} will cause the Gameboy to crash!")
-		cpu.reg.PC++
+		cpu.illegal(0xED, "MISC PREFIX (ED)")
 	}},
 	0xF4: {name: "CALL P, a16", cycles: 4, execute: func(cpu *CPU) {
-		fmt.Println("[Z80 UNUSED] 0xF4 {CALL P, a16} will cause the Gameboy to crash!")
-		cpu.reg.PC++
+		cpu.illegal(0xF4, "CALL P, a16")
 	}},
 	0xFC: {name: "CALL M, a16", cycles: 4, execute: func(cpu *CPU) {
-		fmt.Println("[Z80 UNUSED] 0xFC {CALL M, a16} will cause the Gameboy to crash!")
-		cpu.reg.PC++
+		cpu.illegal(0xFC, "CALL M, a16")
 	}},
 	0xFD: {name: "IY PREFIX", cycles: 4, execute: func(cpu *CPU) {
-		fmt.Println("[Z80 UNUSED] 0xFD {IY PREFIX} will cause the Gameboy to crash!")
-		cpu.reg.PC++
+		cpu.illegal(0xFD, "IY PREFIX")
 	}},
 }
+
+// OpcodeName returns the mnemonic of an opcode, if gemu implements it
+func OpcodeName(op uint8) (string, bool) {
+	instruction, ok := opcodes[op]
+	return instruction.name, ok
+}
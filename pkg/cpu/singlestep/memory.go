@@ -0,0 +1,76 @@
+/*
+		gemu - the gameboy emulator
+				<3 m0x
+	    __________________________
+	   |                          |
+	   | .----------------------. |
+	   | |  .----------------.  | |
+	   | |  |                |  | |
+	   | |))|                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  '----------------'  | |
+	   | |__GAME BOY____________/ |
+	   |          ________        |
+	   |    .    (Nintendo)       |
+	   |  _| |_   """"""""   .-.  |
+	   |-[_   _]-       .-. (   ) |
+	   |   |_|         (   ) '-'  |
+	   |    '           '-'   A   |
+	   |                 B        |
+	   |          ___   ___       |
+	   |         (___) (___)  ,., |
+	   |        select start ;:;: |
+	   |                    ,;:;' /
+	   |                   ,:;:'.'
+	   '-----------------------`
+*/
+package singlestep
+
+// Memory is a flat 64 KiB bus with nothing mapped, which records what's on it each M-cycle
+type Memory struct {
+	data [0x10000]uint8
+
+	ticks    int
+	accesses map[int]Cycle
+}
+
+// Read reads from memory
+func (m *Memory) Read(addr uint16) uint8 {
+	m.record(Cycle{Addr: addr, Value: m.data[addr], HasValue: true, Kind: "r-m"})
+	return m.data[addr]
+}
+
+// Write writes to memory
+func (m *Memory) Write(addr uint16, value uint8) {
+	m.record(Cycle{Addr: addr, Value: value, HasValue: true, Kind: "-wm"})
+	m.data[addr] = value
+}
+
+// Tick advances time, the CPU ticks before each access so it lands in the M-cycle just started
+func (m *Memory) Tick(cycles int) {
+	m.ticks += cycles
+}
+
+// record notes an access in the current M-cycle
+func (m *Memory) record(c Cycle) {
+	if m.accesses == nil {
+		m.accesses = make(map[int]Cycle)
+	}
+	m.accesses[m.ticks/4-1] = c
+}
+
+// cycles returns what was on the bus each M-cycle since the accesses were last cleared
+func (m *Memory) cycles() []Cycle {
+	cycles := make([]Cycle, m.ticks/4)
+	for i := range cycles {
+		cycles[i] = Cycle{Kind: "---"}
+		if c, ok := m.accesses[i]; ok {
+			cycles[i] = c
+		}
+	}
+	return cycles
+}
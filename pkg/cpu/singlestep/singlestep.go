@@ -0,0 +1,302 @@
+/*
+		gemu - the gameboy emulator
+				<3 m0x
+	    __________________________
+	   |                          |
+	   | .----------------------. |
+	   | |  .----------------.  | |
+	   | |  |                |  | |
+	   | |))|                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  '----------------'  | |
+	   | |__GAME BOY____________/ |
+	   |          ________        |
+	   |    .    (Nintendo)       |
+	   |  _| |_   """"""""   .-.  |
+	   |-[_   _]-       .-. (   ) |
+	   |   |_|         (   ) '-'  |
+	   |    '           '-'   A   |
+	   |                 B        |
+	   |          ___   ___       |
+	   |         (___) (___)  ,., |
+	   |        select start ;:;: |
+	   |                    ,;:;' /
+	   |                   ,:;:'.'
+	   '-----------------------`
+*/
+package singlestep
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gemu/pkg/cpu"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/*	https://github.com/SingleStepTests/sm83
+
+There is a JSON file per opcode, e.g. "3e.json" or "cb 7c.json", with 1000 cases each.
+A case is the state before and after a single instruction, along with what was on the bus each M-cycle.
+
+	{
+		"name": "3e 0000",
+		"initial": {"pc": 19935, "sp": 59438, "a": 0, "b": 0, ..., "ram": [[19935, 62], [19936, 8]]},
+		"final":   {"pc": 19937, "sp": 59438, "a": 8, "b": 0, ..., "ram": [[19935, 62], [19936, 8]]},
+		"cycles":  [[19935, 62, "r-m"], [19936, 8, "r-m"]]
+	}
+
+Some versions of the tests start with the opcode already fetched, as the real CPU fetches the next
+opcode while finishing the current instruction. Then PC starts one past the opcode, and the last
+cycle is the fetch of the next one. See Overlapped.
+*/
+
+// State is the CPU and memory state at the start or end of a case
+type State struct {
+	PC, SP                 uint16
+	A, B, C, D, E, F, H, L uint8
+
+	// Address and value pairs, only the memory the instruction touches is listed
+	RAM [][2]int
+}
+
+// UnmarshalJSON decodes a state, which has lower case names
+func (s *State) UnmarshalJSON(b []byte) error {
+	var raw struct {
+		PC  uint16   `json:"pc"`
+		SP  uint16   `json:"sp"`
+		A   uint8    `json:"a"`
+		B   uint8    `json:"b"`
+		C   uint8    `json:"c"`
+		D   uint8    `json:"d"`
+		E   uint8    `json:"e"`
+		F   uint8    `json:"f"`
+		H   uint8    `json:"h"`
+		L   uint8    `json:"l"`
+		RAM [][2]int `json:"ram"`
+	}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	*s = State{raw.PC, raw.SP, raw.A, raw.B, raw.C, raw.D, raw.E, raw.F, raw.H, raw.L, raw.RAM}
+	return nil
+}
+
+// registers returns the CPU registers of a state
+func (s *State) registers() cpu.Registers {
+	return cpu.Registers{A: s.A, F: s.F, B: s.B, C: s.C, D: s.D, E: s.E, H: s.H, L: s.L, SP: s.SP, PC: s.PC}
+}
+
+// Cycle is what was on the bus during an M-cycle
+type Cycle struct {
+	Addr     uint16
+	Value    uint8
+	HasValue bool   // Internal cycles don't have a value on the bus
+	Kind     string // "r-m" for a read, "-wm" for a write, "---" for an internal cycle
+}
+
+// UnmarshalJSON decodes a cycle, which is an [address, value, kind] array
+func (c *Cycle) UnmarshalJSON(b []byte) error {
+	var raw []interface{}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	if raw == nil {
+		*c = Cycle{Kind: "---"}
+		return nil
+	}
+	if len(raw) != 3 {
+		return fmt.Errorf("expected [address, value, kind], got %s", b)
+	}
+
+	*c = Cycle{}
+	if addr, ok := raw[0].(float64); ok {
+		c.Addr = uint16(addr)
+	}
+	if value, ok := raw[1].(float64); ok {
+		c.Value, c.HasValue = uint8(value), true
+	}
+	c.Kind, _ = raw[2].(string)
+	return nil
+}
+
+func (c Cycle) read() bool  { return strings.HasPrefix(c.Kind, "r") }
+func (c Cycle) write() bool { return len(c.Kind) > 1 && c.Kind[1] == 'w' }
+
+func (c Cycle) String() string {
+	switch {
+	case c.read():
+		return fmt.Sprintf("read $%02X from $%04X", c.Value, c.Addr)
+	case c.write():
+		return fmt.Sprintf("write $%02X to $%04X", c.Value, c.Addr)
+	default:
+		return "internal"
+	}
+}
+
+// Case is a single test case
+type Case struct {
+	Name    string  `json:"name"`
+	Initial State   `json:"initial"`
+	Final   State   `json:"final"`
+	Cycles  []Cycle `json:"cycles"`
+}
+
+// Opcode returns the opcode under test, from the case's name
+func (c *Case) Opcode() (uint8, bool) {
+	fields := strings.Fields(c.Name)
+	if len(fields) == 0 {
+		return 0, false
+	}
+	op, err := strconv.ParseUint(fields[0], 16, 8)
+	return uint8(op), err == nil
+}
+
+// Files returns the test files in a directory, in order
+func Files(dir string) ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	sort.Strings(files)
+	return files, err
+}
+
+// Load loads the cases in a test file
+func Load(path string) ([]Case, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var cases []Case
+	if err := json.Unmarshal(b, &cases); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return cases, nil
+}
+
+// Overlapped reports if the cases start with the opcode already fetched, going by where the opcode is in
+// memory in most of them. It could be at PC by chance either way, but not in most cases.
+func Overlapped(cases []Case) bool {
+	direct, overlapped := 0, 0
+	for _, c := range cases {
+		op, ok := c.Opcode()
+		if !ok {
+			continue
+		}
+		for _, e := range c.Initial.RAM {
+			switch {
+			case uint16(e[0]) == c.Initial.PC && uint8(e[1]) == op:
+				direct++
+			case uint16(e[0]) == c.Initial.PC-1 && uint8(e[1]) == op:
+				overlapped++
+			}
+		}
+	}
+	return overlapped > direct
+}
+
+// Mismatch is a register, memory cell or bus cycle that didn't end up as expected
+type Mismatch struct {
+	What      string
+	Want, Got string
+}
+
+func (m Mismatch) String() string {
+	return fmt.Sprintf("%s: want %s, got %s", m.What, m.Want, m.Got)
+}
+
+// Run runs a case against a fresh CPU on flat memory, returning every mismatch.
+// An error means the instruction couldn't run at all, e.g. because it isn't implemented.
+func Run(c Case, overlapped bool) ([]Mismatch, error) {
+	mem := new(Memory)
+	for _, e := range c.Initial.RAM {
+		mem.data[uint16(e[0])] = uint8(e[1])
+	}
+
+	// With the opcode already fetched, back up so the CPU fetches it itself
+	initial, final := c.Initial.registers(), c.Final.registers()
+	if overlapped {
+		initial.PC--
+		final.PC--
+	}
+
+	core := cpu.New(mem)
+	core.SetRegisters(initial)
+
+	if err := core.Step(); err != nil {
+		var unimplemented *cpu.UnimplementedOpcodeError
+		if errors.As(err, &unimplemented) {
+			return nil, err
+		}
+		return []Mismatch{{What: "error", Want: "none", Got: err.Error()}}, nil
+	}
+
+	var mismatches []Mismatch
+	got := core.Registers()
+	for _, r := range []struct {
+		name      string
+		want, got uint16
+		wide      bool
+	}{
+		{"A", uint16(final.A), uint16(got.A), false},
+		{"F", uint16(final.F), uint16(got.F), false},
+		{"B", uint16(final.B), uint16(got.B), false},
+		{"C", uint16(final.C), uint16(got.C), false},
+		{"D", uint16(final.D), uint16(got.D), false},
+		{"E", uint16(final.E), uint16(got.E), false},
+		{"H", uint16(final.H), uint16(got.H), false},
+		{"L", uint16(final.L), uint16(got.L), false},
+		{"SP", final.SP, got.SP, true},
+		{"PC", final.PC, got.PC, true},
+	} {
+		if r.want == r.got {
+			continue
+		}
+		format := "$%02X"
+		if r.wide {
+			format = "$%04X"
+		}
+		mismatches = append(mismatches, Mismatch{What: r.name, Want: fmt.Sprintf(format, r.want), Got: fmt.Sprintf(format, r.got)})
+	}
+
+	for _, e := range c.Final.RAM {
+		addr, want := uint16(e[0]), uint8(e[1])
+		if got := mem.data[addr]; got != want {
+			mismatches = append(mismatches, Mismatch{What: fmt.Sprintf("[$%04X]", addr), Want: fmt.Sprintf("$%02X", want), Got: fmt.Sprintf("$%02X", got)})
+		}
+	}
+
+	return append(mismatches, compareCycles(c.Cycles, mem.cycles(), overlapped)...), nil
+}
+
+// compareCycles compares the bus activity of each M-cycle. Internal cycles aren't compared, as what's on
+// the bus during them isn't something gemu models.
+func compareCycles(want, got []Cycle, overlapped bool) []Mismatch {
+	// Line up the expected cycles with ours, which start with the opcode fetch instead of ending with the next one
+	if overlapped && len(want) > 0 && len(got) > 0 {
+		want, got = want[:len(want)-1], got[1:]
+	}
+
+	if len(want) != len(got) {
+		return []Mismatch{{What: "M-cycles", Want: strconv.Itoa(len(want)), Got: strconv.Itoa(len(got))}}
+	}
+
+	var mismatches []Mismatch
+	for i := range want {
+		w, g := want[i], got[i]
+		if !w.read() && !w.write() {
+			continue
+		}
+		if w.read() != g.read() || w.write() != g.write() || w.Addr != g.Addr || (w.HasValue && w.Value != g.Value) {
+			mismatches = append(mismatches, Mismatch{What: fmt.Sprintf("M-cycle %d", i+1), Want: w.String(), Got: g.String()})
+		}
+	}
+	return mismatches
+}
@@ -0,0 +1,182 @@
+/*
+		gemu - the gameboy emulator
+				<3 m0x
+	    __________________________
+	   |                          |
+	   | .----------------------. |
+	   | |  .----------------.  | |
+	   | |  |                |  | |
+	   | |))|                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  '----------------'  | |
+	   | |__GAME BOY____________/ |
+	   |          ________        |
+	   |    .    (Nintendo)       |
+	   |  _| |_   """"""""   .-.  |
+	   |-[_   _]-       .-. (   ) |
+	   |   |_|         (   ) '-'  |
+	   |    '           '-'   A   |
+	   |                 B        |
+	   |          ___   ___       |
+	   |         (___) (___)  ,., |
+	   |        select start ;:;: |
+	   |                    ,;:;' /
+	   |                   ,:;:'.'
+	   '-----------------------`
+*/
+package cpu_test
+
+import (
+	"errors"
+	"gemu/pkg/cpu"
+	"gemu/pkg/cpu/singlestep"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// maxFailures is how many failing cases are reported per opcode, the rest usually fail the same way
+const maxFailures = 5
+
+// TestSingleStep runs the SingleStepTests SM83 suite in GEMU_SST_DIR, the directory of JSON files.
+// It's skipped when the variable isn't set, and opcodes the CPU doesn't implement yet are skipped.
+func TestSingleStep(t *testing.T) {
+	dir := os.Getenv("GEMU_SST_DIR")
+	if dir == "" {
+		t.Skip("GEMU_SST_DIR isn't set")
+	}
+	files, err := singlestep.Files(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Skipf("no tests in %s", dir)
+	}
+	runFiles(t, files, false)
+}
+
+// TestSingleStepFixtures runs the few cases in testdata, in both the plain and prefetched formats,
+// so the runner itself is tested without the suite. Every opcode in them has to be implemented.
+func TestSingleStepFixtures(t *testing.T) {
+	for _, format := range []struct {
+		dir        string
+		overlapped bool
+	}{
+		{"plain", false},
+		{"prefetched", true},
+	} {
+		format := format
+		t.Run(format.dir, func(t *testing.T) {
+			files, err := singlestep.Files(filepath.Join("testdata", "singlestep", format.dir))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(files) == 0 {
+				t.Fatal("no fixtures")
+			}
+			for _, file := range files {
+				cases, err := singlestep.Load(file)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got := singlestep.Overlapped(cases); got != format.overlapped {
+					t.Errorf("%s: Overlapped = %v, want %v", file, got, format.overlapped)
+				}
+			}
+			runFiles(t, files, true)
+		})
+	}
+}
+
+// TestSingleStepMismatches checks that wrong results are caught, in memory and on the bus
+func TestSingleStepMismatches(t *testing.T) {
+	for _, format := range []string{"plain", "prefetched"} {
+		cases, err := singlestep.Load(filepath.Join("testdata", "singlestep", format, "77.json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		overlapped := singlestep.Overlapped(cases)
+
+		// LD (HL), A writes A to $C123, expect something else there and on the bus
+		c := cases[0]
+		c.Final.RAM = [][2]int{{0xC123, 0x98}}
+		c.Cycles = append([]singlestep.Cycle(nil), c.Cycles...)
+		for i := range c.Cycles {
+			if c.Cycles[i].Addr == 0xC123 {
+				c.Cycles[i].Value = 0x98
+			}
+		}
+		mismatches, err := singlestep.Run(c, overlapped)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, m := range mismatches {
+			got = append(got, m.What)
+		}
+		want := "[$C123] M-cycle 2"
+		if overlapped {
+			want = "[$C123] M-cycle 1"
+		}
+		if strings.Join(got, " ") != want {
+			t.Errorf("%s: mismatches %q, want %q", format, got, want)
+		}
+
+		// A missing M-cycle is a mismatch too
+		c = cases[0]
+		c.Cycles = c.Cycles[:len(c.Cycles)-1]
+		mismatches, err = singlestep.Run(c, overlapped)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(mismatches) != 1 || mismatches[0].What != "M-cycles" {
+			t.Errorf("%s: mismatches %v, want the M-cycle count", format, mismatches)
+		}
+	}
+}
+
+// runFiles runs the cases in each file as a subtest. Unimplemented opcodes fail when strict, and are skipped otherwise.
+func runFiles(t *testing.T, files []string, strict bool) {
+	for _, file := range files {
+		file := file
+		t.Run(strings.TrimSuffix(filepath.Base(file), ".json"), func(t *testing.T) {
+			cases, err := singlestep.Load(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			overlapped := singlestep.Overlapped(cases)
+
+			failures := 0
+			for _, c := range cases {
+				mismatches, err := singlestep.Run(c, overlapped)
+				var unimplemented *cpu.UnimplementedOpcodeError
+				if errors.As(err, &unimplemented) {
+					if strict {
+						t.Fatal(err)
+					}
+					t.Skip(err)
+				}
+				if len(mismatches) == 0 {
+					continue
+				}
+
+				failures++
+				if failures <= maxFailures {
+					var lines []string
+					for _, m := range mismatches {
+						lines = append(lines, "    "+m.String())
+					}
+					t.Errorf("%s:\n%s", c.Name, strings.Join(lines, "\n"))
+				}
+			}
+			if failures > maxFailures {
+				t.Errorf("%d of %d cases failed", failures, len(cases))
+			}
+		})
+	}
+}
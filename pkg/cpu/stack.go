@@ -30,19 +30,23 @@
 */
 package cpu
 
-import "fmt"
+import "gemu/pkg/logger"
 
 // stackPush pushes a value onto the stack.
 func (cpu *CPU) stackPush(b uint8) {
 	cpu.reg.SP--
-	cpu.mem.Write(cpu.reg.SP, b)
-	fmt.Printf("[Stack] Write: %02x to %x\n", b, cpu.reg.SP)
+	cpu.write(cpu.reg.SP, b)
+	if logger.Stack.Enabled(logger.Trace) {
+		logger.Stack.Tracef("Write: %02x to %x", b, cpu.reg.SP)
+	}
 }
 
 // stackPop pops a value from the stack.
 func (cpu *CPU) stackPop() uint8 {
-	b := cpu.mem.Read(cpu.reg.SP)
+	b := cpu.read(cpu.reg.SP)
 	cpu.reg.SP++
-	fmt.Printf("[Stack] Read: %02x from %x\n", b, cpu.reg.SP)
+	if logger.Stack.Enabled(logger.Trace) {
+		logger.Stack.Tracef("Read: %02x from %x", b, cpu.reg.SP)
+	}
 	return b
 }
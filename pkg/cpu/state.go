@@ -0,0 +1,57 @@
+/*
+		gemu - the gameboy emulator
+				<3 m0x
+	    __________________________
+	   |                          |
+	   | .----------------------. |
+	   | |  .----------------.  | |
+	   | |  |                |  | |
+	   | |))|                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  '----------------'  | |
+	   | |__GAME BOY____________/ |
+	   |          ________        |
+	   |    .    (Nintendo)       |
+	   |  _| |_   """"""""   .-.  |
+	   |-[_   _]-       .-. (   ) |
+	   |   |_|         (   ) '-'  |
+	   |    '           '-'   A   |
+	   |                 B        |
+	   |          ___   ___       |
+	   |         (___) (___)  ,., |
+	   |        select start ;:;: |
+	   |                    ,;:;' /
+	   |                   ,:;:'.'
+	   '-----------------------`
+*/
+package cpu
+
+import "gemu/pkg/savestate"
+
+// state is the CPU's section of a save state
+type state struct {
+	Reg            Registers
+	Cycles         uint64
+	Halted, Locked bool
+}
+
+// SaveState writes the CPU's state
+func (cpu *CPU) SaveState(e *savestate.Encoder) {
+	e.Section("CPU ", state{Reg: cpu.reg, Cycles: cpu.cycles, Halted: cpu.halted, Locked: cpu.locked})
+}
+
+// LoadState restores the CPU's state
+func (cpu *CPU) LoadState(d *savestate.Decoder) error {
+	var s state
+	if err := d.Section("CPU ", &s); err != nil {
+		return err
+	}
+
+	cpu.reg, cpu.cycles, cpu.halted, cpu.locked = s.Reg, s.Cycles, s.Halted, s.Locked
+	cpu.err = nil
+	return nil
+}
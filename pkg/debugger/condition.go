@@ -0,0 +1,249 @@
+/*
+		gemu - the gameboy emulator
+				<3 m0x
+	    __________________________
+	   |                          |
+	   | .----------------------. |
+	   | |  .----------------.  | |
+	   | |  |                |  | |
+	   | |))|                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  '----------------'  | |
+	   | |__GAME BOY____________/ |
+	   |          ________        |
+	   |    .    (Nintendo)       |
+	   |  _| |_   """"""""   .-.  |
+	   |-[_   _]-       .-. (   ) |
+	   |   |_|         (   ) '-'  |
+	   |    '           '-'   A   |
+	   |                 B        |
+	   |          ___   ___       |
+	   |         (___) (___)  ,., |
+	   |        select start ;:;: |
+	   |                    ,;:;' /
+	   |                   ,:;:'.'
+	   '-----------------------`
+*/
+package debugger
+
+import (
+	"fmt"
+	"gemu/pkg/cpu"
+	"strconv"
+	"strings"
+)
+
+/*
+Breakpoint conditions are one or more comparisons joined with &&, e.g.
+
+	A == 0x10
+	HL >= 0xC000 && [HL] != 0
+	value == $FF
+
+Operands can be registers (A F B C D E H L AF BC DE HL SP PC), numbers, memory reads ([0xFF44], [HL]),
+or "value", the byte read or written by a watchpoint. Numbers are hex when prefixed with 0x or $,
+and decimal otherwise.
+*/
+
+// operand is one side of a comparison
+type operand struct {
+	reg   string // Register name, or "value"
+	num   uint16 // Literal value, when reg is empty
+	deref bool   // Read memory at the address the operand evaluates to
+}
+
+// comparison compares two operands
+type comparison struct {
+	lhs, rhs operand
+	op       string
+}
+
+// condition is a set of comparisons that must all hold
+type condition []comparison
+
+// env is what conditions are evaluated against
+type env struct {
+	reg   cpu.Registers
+	read  func(addr uint16) uint8
+	value uint8 // Byte accessed, for watchpoints
+}
+
+var comparisonOps = []string{"==", "!=", "<=", ">=", "<", ">"}
+
+// parseCondition parses a condition expression
+func parseCondition(expr string) (condition, error) {
+	var cond condition
+	for _, part := range strings.Split(expr, "&&") {
+		part = strings.TrimSpace(part)
+
+		c := comparison{}
+		for _, op := range comparisonOps {
+			if i := strings.Index(part, op); i >= 0 {
+				c.op = op
+				lhs, rhs := part[:i], part[i+len(op):]
+
+				var err error
+				if c.lhs, err = parseOperand(lhs); err != nil {
+					return nil, err
+				}
+				if c.rhs, err = parseOperand(rhs); err != nil {
+					return nil, err
+				}
+				break
+			}
+		}
+		if c.op == "" {
+			return nil, fmt.Errorf("no comparison in %q, expected one of %s", part, strings.Join(comparisonOps, " "))
+		}
+
+		cond = append(cond, c)
+	}
+	return cond, nil
+}
+
+// parseOperand parses a single operand
+func parseOperand(s string) (operand, error) {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "[") && strings.HasSuffix(s, "]") {
+		o, err := parseOperand(s[1 : len(s)-1])
+		if o.deref {
+			return o, fmt.Errorf("can't nest memory reads in %q", s)
+		}
+		o.deref = true
+		return o, err
+	}
+
+	name := strings.ToUpper(s)
+	switch name {
+	case "A", "F", "B", "C", "D", "E", "H", "L", "AF", "BC", "DE", "HL", "SP", "PC":
+		return operand{reg: name}, nil
+	case "VALUE":
+		return operand{reg: name}, nil
+	}
+
+	n, err := parseNumber(s)
+	if err != nil {
+		return operand{}, err
+	}
+	return operand{num: n}, nil
+}
+
+// parseNumber parses a 16-bit number, hex when prefixed with 0x or $ and decimal otherwise
+func parseNumber(s string) (uint16, error) {
+	s = strings.TrimSpace(s)
+	base := 10
+	switch {
+	case strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X"):
+		s, base = s[2:], 16
+	case strings.HasPrefix(s, "$"):
+		s, base = s[1:], 16
+	}
+
+	n, err := strconv.ParseUint(s, base, 16)
+	if err != nil {
+		return 0, fmt.Errorf("bad number %q", s)
+	}
+	return uint16(n), nil
+}
+
+// eval evaluates an operand
+func (o operand) eval(e env) uint16 {
+	v := o.num
+	switch o.reg {
+	case "":
+	case "VALUE":
+		v = uint16(e.value)
+	default:
+		v = regValue(e.reg, o.reg)
+	}
+
+	if o.deref {
+		return uint16(e.read(v))
+	}
+	return v
+}
+
+// eval reports if every comparison in the condition holds. An empty condition always holds.
+func (c condition) eval(e env) bool {
+	for _, cmp := range c {
+		l, r := cmp.lhs.eval(e), cmp.rhs.eval(e)
+
+		var ok bool
+		switch cmp.op {
+		case "==":
+			ok = l == r
+		case "!=":
+			ok = l != r
+		case "<=":
+			ok = l <= r
+		case ">=":
+			ok = l >= r
+		case "<":
+			ok = l < r
+		case ">":
+			ok = l > r
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// regValue returns the value of a register by name
+func regValue(reg cpu.Registers, name string) uint16 {
+	switch name {
+	case "A":
+		return uint16(reg.A)
+	case "F":
+		return uint16(reg.F)
+	case "B":
+		return uint16(reg.B)
+	case "C":
+		return uint16(reg.C)
+	case "D":
+		return uint16(reg.D)
+	case "E":
+		return uint16(reg.E)
+	case "H":
+		return uint16(reg.H)
+	case "L":
+		return uint16(reg.L)
+	case "AF":
+		return uint16(reg.A)<<8 | uint16(reg.F)
+	case "BC":
+		return uint16(reg.B)<<8 | uint16(reg.C)
+	case "DE":
+		return uint16(reg.D)<<8 | uint16(reg.E)
+	case "HL":
+		return uint16(reg.H)<<8 | uint16(reg.L)
+	case "SP":
+		return reg.SP
+	case "PC":
+		return reg.PC
+	}
+	return 0
+}
+
+func (o operand) String() string {
+	s := o.reg
+	if s == "" {
+		s = fmt.Sprintf("$%04X", o.num)
+	}
+	if o.deref {
+		return "[" + s + "]"
+	}
+	return s
+}
+
+func (c condition) String() string {
+	parts := make([]string, len(c))
+	for i, cmp := range c {
+		parts[i] = fmt.Sprintf("%s %s %s", cmp.lhs, cmp.op, cmp.rhs)
+	}
+	return strings.Join(parts, " && ")
+}
@@ -0,0 +1,702 @@
+/*
+		gemu - the gameboy emulator
+				<3 m0x
+	    __________________________
+	   |                          |
+	   | .----------------------. |
+	   | |  .----------------.  | |
+	   | |  |                |  | |
+	   | |))|                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  '----------------'  | |
+	   | |__GAME BOY____________/ |
+	   |          ________        |
+	   |    .    (Nintendo)       |
+	   |  _| |_   """"""""   .-.  |
+	   |-[_   _]-       .-. (   ) |
+	   |   |_|         (   ) '-'  |
+	   |    '           '-'   A   |
+	   |                 B        |
+	   |          ___   ___       |
+	   |         (___) (___)  ,., |
+	   |        select start ;:;: |
+	   |                    ,;:;' /
+	   |                   ,:;:'.'
+	   '-----------------------`
+*/
+package debugger
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"gemu/pkg/cpu"
+	"gemu/pkg/disasm"
+	"gemu/pkg/gb"
+	"gemu/pkg/interrupt"
+	"gemu/pkg/mmu"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// ErrQuit is returned when the user quits the debugger
+var ErrQuit = errors.New("quit from debugger")
+
+// kind is what triggers a breakpoint
+type kind int
+
+const (
+	kindPC     = kind(iota) // Executing the instruction at addr
+	kindRead                // Reading from addr
+	kindWrite               // Writing to addr
+	kindAccess              // Reading from or writing to addr
+)
+
+func (k kind) String() string {
+	switch k {
+	case kindPC:
+		return "break"
+	case kindRead:
+		return "watch r"
+	case kindWrite:
+		return "watch w"
+	case kindAccess:
+		return "watch rw"
+	default:
+		return fmt.Sprintf("%d", int(k))
+	}
+}
+
+// breakpoint stops execution when it's triggered and its condition holds
+type breakpoint struct {
+	id      int
+	kind    kind
+	addr    uint16
+	bank    int // ROM bank for breakpoints on switchable ROM set by label, -1 for any bank
+	cond    condition
+	enabled bool
+	hits    int
+}
+
+// historySize is how many of the previously executed instructions are kept, for disassembly around PC
+const historySize = 8
+
+// Debugger is an interactive command line debugger for the GameBoy
+type Debugger struct {
+	gb  *gb.GameBoy
+	in  *bufio.Scanner
+	out io.Writer
+
+	breakpoints map[int]*breakpoint
+	nextID      int
+
+	// Set by the CPU access hook when a watchpoint triggers, and checked after each instruction
+	watchHit  *breakpoint
+	watchDesc string
+
+	// Temporary breakpoint for run-to and step-over
+	runTo     uint16
+	runToBank int
+	runToSet  bool
+
+	// Addresses of the last instructions executed, oldest first
+	history []uint16
+
+	// Set by Ctrl-C to stop a running program
+	interrupted int32
+
+	// The last command entered, repeated by an empty line
+	last string
+}
+
+// New creates a debugger for an initialized GameBoy, reading commands from in and writing to out
+func New(gameboy *gb.GameBoy, in io.Reader, out io.Writer) *Debugger {
+	d := &Debugger{
+		gb:          gameboy,
+		in:          bufio.NewScanner(in),
+		out:         out,
+		breakpoints: make(map[int]*breakpoint),
+		nextID:      1,
+	}
+	gameboy.CPU().OnAccess = d.onAccess
+	return d
+}
+
+// Run runs the debugger REPL until the user quits, with the GameBoy stopped at its current instruction
+func (d *Debugger) Run() error {
+	stop := d.catchInterrupts()
+	defer stop()
+
+	d.printf("gemu debugger, type help for a list of commands\n")
+	d.printLocation()
+	err := d.repl(false)
+	if errors.Is(err, ErrQuit) {
+		return nil
+	}
+	return err
+}
+
+// Break hands an emulation error to the debugger, and is meant to be used as gb.GameBoy.OnBreak.
+// Continuing hands control back to the emulator, breakpoints only apply while the debugger is driving.
+func (d *Debugger) Break(err error) error {
+	stop := d.catchInterrupts()
+	defer stop()
+
+	d.printf("*** %s fault: %s\n", gb.Classify(err), err)
+	d.printLocation()
+	return d.repl(true)
+}
+
+// catchInterrupts makes Ctrl-C stop a running program instead of the whole emulator
+func (d *Debugger) catchInterrupts() func() {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt)
+	go func() {
+		for range c {
+			atomic.StoreInt32(&d.interrupted, 1)
+		}
+	}()
+	return func() {
+		signal.Stop(c)
+		close(c)
+	}
+}
+
+// repl reads and runs commands. When resumable, continue returns nil to hand control back to the caller.
+func (d *Debugger) repl(resumable bool) error {
+	for {
+		d.printf("(gemu) ")
+		if !d.in.Scan() {
+			d.printf("\n")
+			return ErrQuit
+		}
+
+		line := strings.TrimSpace(d.in.Text())
+		if line == "" {
+			line = d.last
+		}
+		d.last = line
+
+		args := strings.Fields(line)
+		if len(args) == 0 {
+			continue
+		}
+
+		cmd, args := args[0], args[1:]
+		if resumable && (cmd == "c" || cmd == "continue") {
+			return nil
+		}
+		if cmd == "q" || cmd == "quit" {
+			return ErrQuit
+		}
+
+		if err := d.exec(cmd, args); err != nil {
+			d.printf("%s\n", err)
+		}
+	}
+}
+
+// exec runs a single command
+func (d *Debugger) exec(cmd string, args []string) error {
+	switch cmd {
+	case "h", "help":
+		d.help()
+	case "s", "step":
+		n, err := countArg(args)
+		if err != nil {
+			return err
+		}
+		for i := 0; i < n; i++ {
+			if stopped := d.step(); stopped {
+				break
+			}
+		}
+		d.printLocation()
+	case "n", "next":
+		d.next()
+	case "c", "continue":
+		d.cont()
+	case "u", "until", "runto":
+		if len(args) != 1 {
+			return errors.New("usage: until <addr>")
+		}
+		addr, bank, err := d.address(args[0])
+		if err != nil {
+			return err
+		}
+		d.runTo, d.runToBank, d.runToSet = addr, bank, true
+		d.cont()
+	case "b", "break":
+		return d.addBreakpoint(kindPC, args)
+	case "w", "watch":
+		if len(args) < 1 {
+			return errors.New("usage: watch r|w|rw <addr> [if <condition>]")
+		}
+		k := map[string]kind{"r": kindRead, "w": kindWrite, "rw": kindAccess}
+		wk, ok := k[args[0]]
+		if !ok {
+			return fmt.Errorf("unknown watchpoint type %q, expected r, w or rw", args[0])
+		}
+		return d.addBreakpoint(wk, args[1:])
+	case "del", "delete":
+		return d.setBreakpoints(args, func(id int) { delete(d.breakpoints, id) })
+	case "enable":
+		return d.setBreakpoints(args, func(id int) { d.breakpoints[id].enabled = true })
+	case "disable":
+		return d.setBreakpoints(args, func(id int) { d.breakpoints[id].enabled = false })
+	case "bl", "breakpoints":
+		d.listBreakpoints()
+	case "r", "regs", "registers":
+		d.printRegisters()
+	case "x", "mem":
+		return d.dump(args)
+	case "d", "disasm":
+		return d.disasm(args)
+	default:
+		return fmt.Errorf("unknown command %q, type help for a list of commands", cmd)
+	}
+	return nil
+}
+
+func (d *Debugger) help() {
+	d.printf(`Commands:
+  s, step [n]                        step n instructions (default 1)
+  n, next                            step over calls
+  c, continue                        run until a breakpoint, error or Ctrl-C
+  u, until <addr>                    run until PC reaches addr
+  b, break <addr> [if <cond>]        break when PC reaches addr
+  w, watch r|w|rw <addr> [if <cond>] break when addr is read, written or either
+  bl, breakpoints                    list breakpoints
+  del, delete <id>...                delete breakpoints
+  enable/disable <id>...             enable or disable breakpoints
+  r, regs                            show registers and flags
+  x, mem <addr|region> [len]         hex dump memory, e.g. "x 0xC000 32" or "x HRAM"
+  d, disasm [addr] [n]               disassemble n instructions from addr, or around PC
+  q, quit                            quit
+An empty line repeats the last command.
+
+Conditions compare registers, numbers, memory and the watched value, joined with &&:
+  A == 0x10 && [HL] != 0        value >= $80
+Numbers are hex with a 0x or $ prefix, decimal otherwise.
+With symbols loaded, addresses can also be labels with an optional offset, e.g. "b Main.loop+3".
+Regions: `)
+	var names []string
+	for r := mmu.ROM0; r <= mmu.IE; r++ {
+		names = append(names, r.String())
+	}
+	d.printf("%s\n", strings.Join(names, " "))
+}
+
+// step executes a single instruction, reporting if execution stopped because of an error or watchpoint
+func (d *Debugger) step() (stopped bool) {
+	pc := d.gb.CPU().Registers().PC
+	d.history = append(d.history, pc)
+	if len(d.history) > historySize {
+		d.history = d.history[1:]
+	}
+
+	d.watchHit = nil
+	if err := d.gb.Step(); err != nil {
+		d.printf("*** %s fault: %s\n", gb.Classify(err), err)
+		return true
+	}
+
+	// Step only reports the lockup once, after that the CPU just sits there
+	if d.gb.Locked() {
+		d.printf("*** CPU locked up at %s\n", d.location(d.gb.CPU().Registers().PC))
+		return true
+	}
+
+	if d.watchHit != nil {
+		d.watchHit.hits++
+		d.printf("*** watchpoint %d: %s\n", d.watchHit.id, d.watchDesc)
+		return true
+	}
+	return false
+}
+
+// next steps over the instruction at PC, running until a call returns
+func (d *Debugger) next() {
+	reg := d.gb.CPU().Registers()
+	ins := d.instruction(reg.PC)
+	if strings.HasPrefix(ins.Mnemonic, "CALL") || strings.HasPrefix(ins.Mnemonic, "RST") {
+		d.runTo, d.runToBank, d.runToSet = reg.PC+uint16(ins.Len()), -1, true
+		d.cont()
+		return
+	}
+
+	d.step()
+	d.printLocation()
+}
+
+// cont runs until a breakpoint triggers, an error happens or the user hits Ctrl-C
+func (d *Debugger) cont() {
+	atomic.StoreInt32(&d.interrupted, 0)
+	defer func() { d.runToSet = false }()
+
+	first := true
+	for {
+		pc := d.gb.CPU().Registers().PC
+
+		// Don't break on the instruction we're continuing from
+		if !first {
+			if d.runToSet && pc == d.runTo && d.inBank(d.runToBank, pc) {
+				break
+			}
+			if bp := d.pcBreakpoint(pc); bp != nil {
+				bp.hits++
+				d.printf("*** breakpoint %d at %s\n", bp.id, d.location(pc))
+				break
+			}
+		}
+		first = false
+
+		if d.step() {
+			break
+		}
+		if atomic.LoadInt32(&d.interrupted) != 0 {
+			d.printf("*** interrupted\n")
+			break
+		}
+	}
+	d.printLocation()
+}
+
+// pcBreakpoint returns the enabled breakpoint at pc whose condition holds, if any
+func (d *Debugger) pcBreakpoint(pc uint16) *breakpoint {
+	for _, bp := range d.sortedBreakpoints() {
+		if bp.enabled && bp.kind == kindPC && bp.addr == pc && d.inBank(bp.bank, pc) && bp.cond.eval(d.env(0)) {
+			return bp
+		}
+	}
+	return nil
+}
+
+// onAccess is the CPU's memory access hook, which checks watchpoints
+func (d *Debugger) onAccess(addr uint16, value uint8, write bool) {
+	if d.watchHit != nil {
+		return
+	}
+
+	for _, bp := range d.sortedBreakpoints() {
+		if !bp.enabled || bp.addr != addr {
+			continue
+		}
+
+		hit := (bp.kind == kindAccess) || (bp.kind == kindRead && !write) || (bp.kind == kindWrite && write)
+		if hit && bp.cond.eval(d.env(value)) {
+			d.watchHit = bp
+			if write {
+				d.watchDesc = fmt.Sprintf("wrote $%02X to $%04X", value, addr)
+			} else {
+				d.watchDesc = fmt.Sprintf("read $%02X from $%04X", value, addr)
+			}
+			return
+		}
+	}
+}
+
+// env returns the environment conditions are evaluated in
+func (d *Debugger) env(value uint8) env {
+	return env{reg: d.gb.CPU().Registers(), read: d.gb.MMU().Read, value: value}
+}
+
+// addBreakpoint parses "<addr> [if <condition>]" and adds a breakpoint
+func (d *Debugger) addBreakpoint(k kind, args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("usage: %s <addr> [if <condition>]", k)
+	}
+
+	addr, bank, err := d.address(args[0])
+	if err != nil {
+		return err
+	}
+
+	var cond condition
+	if len(args) > 1 {
+		if args[1] != "if" || len(args) < 3 {
+			return errors.New("expected if <condition> after the address")
+		}
+		if cond, err = parseCondition(strings.Join(args[2:], " ")); err != nil {
+			return err
+		}
+	}
+
+	bp := &breakpoint{id: d.nextID, kind: k, addr: addr, bank: bank, cond: cond, enabled: true}
+	d.breakpoints[bp.id] = bp
+	d.nextID++
+
+	d.printf("%d: %s\n", bp.id, d.describe(bp))
+	return nil
+}
+
+// setBreakpoints applies fn to each breakpoint id in args
+func (d *Debugger) setBreakpoints(args []string, fn func(id int)) error {
+	if len(args) == 0 {
+		return errors.New("expected one or more breakpoint ids")
+	}
+	for _, arg := range args {
+		id, err := strconv.Atoi(arg)
+		if err != nil {
+			return fmt.Errorf("bad breakpoint id %q", arg)
+		}
+		if _, ok := d.breakpoints[id]; !ok {
+			return fmt.Errorf("no breakpoint %d", id)
+		}
+		fn(id)
+	}
+	return nil
+}
+
+func (d *Debugger) listBreakpoints() {
+	if len(d.breakpoints) == 0 {
+		d.printf("no breakpoints\n")
+		return
+	}
+	for _, bp := range d.sortedBreakpoints() {
+		state := ""
+		if !bp.enabled {
+			state = " (disabled)"
+		}
+		d.printf("%d: %s, hit %d times%s\n", bp.id, d.describe(bp), bp.hits, state)
+	}
+}
+
+func (d *Debugger) describe(bp *breakpoint) string {
+	s := fmt.Sprintf("%s $%04X", bp.kind, bp.addr)
+	if bp.bank >= 0 {
+		s = fmt.Sprintf("%s %02X:%04X", bp.kind, bp.bank, bp.addr)
+	}
+	if name := d.gb.Symbols.Describe(d.bankFor(bp.bank), bp.addr); name != "" {
+		s += " <" + name + ">"
+	}
+	s += fmt.Sprintf(" (%s)", mmu.MapAddr(bp.addr))
+	if len(bp.cond) > 0 {
+		s += " if " + bp.cond.String()
+	}
+	return s
+}
+
+func (d *Debugger) sortedBreakpoints() []*breakpoint {
+	bps := make([]*breakpoint, 0, len(d.breakpoints))
+	for _, bp := range d.breakpoints {
+		bps = append(bps, bp)
+	}
+	sort.Slice(bps, func(i, j int) bool { return bps[i].id < bps[j].id })
+	return bps
+}
+
+// printRegisters shows the registers, flags and CPU state
+func (d *Debugger) printRegisters() {
+	c := d.gb.CPU()
+	reg := c.Registers()
+
+	flags := []byte("----")
+	for i, f := range []uint8{cpu.FlagZ, cpu.FlagN, cpu.FlagH, cpu.FlagC} {
+		if reg.F&f != 0 {
+			flags[i] = "ZNHC"[i]
+		}
+	}
+
+	d.printf("A:%02X F:%02X [%s] B:%02X C:%02X D:%02X E:%02X H:%02X L:%02X SP:%04X PC:%04X\n",
+		reg.A, reg.F, flags, reg.B, reg.C, reg.D, reg.E, reg.H, reg.L, reg.SP, reg.PC)
+	d.printf("cycles:%d halted:%t locked:%t IF:%02X IE:%02X\n",
+		c.Cycles(), c.Halted(), c.Locked(), d.gb.MMU().Read(interrupt.IF), d.gb.MMU().Read(interrupt.IE))
+}
+
+// printLocation shows the instruction at PC
+func (d *Debugger) printLocation() {
+	pc := d.gb.CPU().Registers().PC
+	d.printf("%s\n", d.format(pc, true))
+}
+
+// dump hex dumps memory, by address or region
+func (d *Debugger) dump(args []string) error {
+	if len(args) < 1 {
+		return errors.New("usage: x <addr|region> [len]")
+	}
+
+	var start uint16
+	length := 64
+	if r, ok := mmu.ParseRegion(args[0]); ok {
+		s, e := r.Bounds()
+		start, length = s, int(e)-int(s)+1
+	} else {
+		addr, _, err := d.address(args[0])
+		if err != nil {
+			return err
+		}
+		start = addr
+	}
+	if len(args) > 1 {
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n <= 0 {
+			return fmt.Errorf("bad length %q", args[1])
+		}
+		length = n
+	}
+	if int(start)+length > 0x10000 {
+		length = 0x10000 - int(start)
+	}
+
+	region := mmu.MemRegion(-1)
+	for i := 0; i < length; i += 16 {
+		addr := start + uint16(i)
+		if r := mmu.MapAddr(addr); r != region {
+			region = r
+			d.printf("-- %s --\n", region)
+		}
+
+		var hex, ascii strings.Builder
+		for j := 0; j < 16 && i+j < length; j++ {
+			b := d.gb.MMU().Read(addr + uint16(j))
+			fmt.Fprintf(&hex, "%02X ", b)
+			if b >= 0x20 && b < 0x7F {
+				ascii.WriteByte(b)
+			} else {
+				ascii.WriteByte('.')
+			}
+		}
+		d.printf("%04X: %-48s |%s|\n", addr, hex.String(), ascii.String())
+	}
+	return nil
+}
+
+// disasm disassembles from an address, or around PC using the instruction history
+func (d *Debugger) disasm(args []string) error {
+	pc := d.gb.CPU().Registers().PC
+	n := 10
+
+	if len(args) > 0 {
+		addr, _, err := d.address(args[0])
+		if err != nil {
+			return err
+		}
+		if len(args) > 1 {
+			if n, err = strconv.Atoi(args[1]); err != nil || n <= 0 {
+				return fmt.Errorf("bad count %q", args[1])
+			}
+		}
+		for i := 0; i < n; i++ {
+			d.printf("%s\n", d.format(addr, addr == pc))
+			addr += uint16(d.instruction(addr).Len())
+		}
+		return nil
+	}
+
+	// Instructions can't reliably be decoded backwards, so show the ones we actually executed
+	for _, addr := range d.history {
+		if addr != pc {
+			d.printf("%s\n", d.format(addr, false))
+		}
+	}
+	addr := pc
+	for i := 0; i < n; i++ {
+		d.printf("%s\n", d.format(addr, addr == pc))
+		addr += uint16(d.instruction(addr).Len())
+	}
+	return nil
+}
+
+// instruction decodes the instruction at addr
+func (d *Debugger) instruction(addr uint16) disasm.Instruction {
+	return disasm.Decode(d.gb.MMU().Read, addr)
+}
+
+// format formats the instruction at addr as a disassembly line, with jump targets and addresses labelled
+func (d *Debugger) format(addr uint16, current bool) string {
+	ins := d.instruction(addr)
+
+	var raw strings.Builder
+	for _, b := range ins.Bytes {
+		fmt.Fprintf(&raw, "%02X ", b)
+	}
+
+	marker := "  "
+	if current {
+		marker = "=>"
+	}
+	return fmt.Sprintf("%s %s: %-9s %s", marker, d.location(addr), raw.String(), ins.Format(d.label))
+}
+
+// location formats an address along with the label it's in, e.g. "0155 <Main.loop+2>"
+func (d *Debugger) location(addr uint16) string {
+	if d.inBootROM(addr) {
+		return fmt.Sprintf("%04X", addr)
+	}
+	if name := d.gb.Symbols.Describe(d.gb.MMU().ROMBank(), addr); name != "" {
+		return fmt.Sprintf("%04X <%s>", addr, name)
+	}
+	return fmt.Sprintf("%04X", addr)
+}
+
+// label is a disasm.Labeler for the ROM's symbols, in the currently mapped bank
+func (d *Debugger) label(addr uint16) (string, bool) {
+	if d.inBootROM(addr) {
+		return "", false
+	}
+	return d.gb.Symbols.Lookup(d.gb.MMU().ROMBank(), addr)
+}
+
+// inBootROM reports if addr is covered by the boot ROM, which the ROM's symbols don't describe
+func (d *Debugger) inBootROM(addr uint16) bool {
+	return addr < 0x100 && d.gb.MMU().BootROMMapped()
+}
+
+// address parses an address argument, either a number or a label with an optional offset like "Main.loop+3".
+// Labels in switchable ROM also return their bank, otherwise the bank is -1 for any.
+func (d *Debugger) address(arg string) (uint16, int, error) {
+	if addr, err := parseNumber(arg); err == nil {
+		return addr, -1, nil
+	}
+
+	if d.gb.Symbols == nil {
+		return 0, 0, fmt.Errorf("bad address %q, and no symbols are loaded", arg)
+	}
+	sym, err := d.gb.Symbols.Resolve(arg)
+	if err != nil {
+		return 0, 0, err
+	}
+	if sym.Addr < 0x4000 || sym.Addr > 0x7FFF {
+		return sym.Addr, -1, nil
+	}
+	return sym.Addr, sym.Bank, nil
+}
+
+// inBank reports if a breakpoint on a bank applies to pc, given the bank currently mapped
+func (d *Debugger) inBank(bank int, pc uint16) bool {
+	return bank < 0 || pc < 0x4000 || pc > 0x7FFF || bank == d.gb.MMU().ROMBank()
+}
+
+// bankFor returns the bank to look up symbols in, which is the mapped one for breakpoints on any bank
+func (d *Debugger) bankFor(bank int) int {
+	if bank < 0 {
+		return d.gb.MMU().ROMBank()
+	}
+	return bank
+}
+
+// countArg parses an optional count argument
+func countArg(args []string) (int, error) {
+	if len(args) == 0 {
+		return 1, nil
+	}
+	n, err := strconv.Atoi(args[0])
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("bad count %q", args[0])
+	}
+	return n, nil
+}
+
+func (d *Debugger) printf(format string, args ...interface{}) {
+	fmt.Fprintf(d.out, format, args...)
+}
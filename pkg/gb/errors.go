@@ -0,0 +1,142 @@
+/*
+		gemu - the gameboy emulator
+				<3 m0x
+	    __________________________
+	   |                          |
+	   | .----------------------. |
+	   | |  .----------------.  | |
+	   | |  |                |  | |
+	   | |))|                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  '----------------'  | |
+	   | |__GAME BOY____________/ |
+	   |          ________        |
+	   |    .    (Nintendo)       |
+	   |  _| |_   """"""""   .-.  |
+	   |-[_   _]-       .-. (   ) |
+	   |   |_|         (   ) '-'  |
+	   |    '           '-'   A   |
+	   |                 B        |
+	   |          ___   ___       |
+	   |         (___) (___)  ,., |
+	   |        select start ;:;: |
+	   |                    ,;:;' /
+	   |                   ,:;:'.'
+	   '-----------------------`
+*/
+package gb
+
+import (
+	"errors"
+	"fmt"
+	"gemu/pkg/cartridge"
+	"gemu/pkg/cpu"
+	"gemu/pkg/logger"
+	"gemu/pkg/mmu"
+	"strings"
+)
+
+// ErrorPolicy decides what the GameBoy does when emulation raises an error
+type ErrorPolicy int
+
+const (
+	PolicyHalt     = ErrorPolicy(iota) // Stop emulating and return the error from Run
+	PolicyContinue                     // Log the error and keep emulating
+	PolicyBreak                        // Hand the error to the debugger, halting if there isn't one
+)
+
+func (p ErrorPolicy) String() string {
+	switch p {
+	case PolicyHalt:
+		return "halt"
+	case PolicyContinue:
+		return "continue"
+	case PolicyBreak:
+		return "break"
+	default:
+		return fmt.Sprintf("%d", int(p))
+	}
+}
+
+// ParseErrorPolicy returns the ErrorPolicy with the given name
+func ParseErrorPolicy(name string) (ErrorPolicy, error) {
+	for p := PolicyHalt; p <= PolicyBreak; p++ {
+		if strings.EqualFold(name, p.String()) {
+			return p, nil
+		}
+	}
+	return PolicyHalt, fmt.Errorf("unknown error policy %q", name)
+}
+
+// Fault is who is to blame for an emulation error
+type Fault int
+
+const (
+	FaultUnknown  = Fault(iota) // Not an emulation error
+	FaultEmulator               // gemu is missing something real hardware does, e.g. an unimplemented opcode
+	FaultROM                    // The ROM did something that would misbehave on real hardware too
+)
+
+func (f Fault) String() string {
+	switch f {
+	case FaultUnknown:
+		return "unknown"
+	case FaultEmulator:
+		return "emulator"
+	case FaultROM:
+		return "rom"
+	default:
+		return fmt.Sprintf("%d", int(f))
+	}
+}
+
+// Classify reports who is to blame for an error returned by the GameBoy,
+// so automation can tell emulator bugs apart from ROM bugs.
+func Classify(err error) Fault {
+	var unimplemented *cpu.UnimplementedOpcodeError
+	var illegal *cpu.IllegalOpcodeError
+	var access *mmu.AccessError
+	var cart *cartridge.FaultError
+
+	switch {
+	case errors.As(err, &unimplemented):
+		return FaultEmulator
+	case errors.As(err, &illegal), errors.As(err, &access), errors.As(err, &cart):
+		return FaultROM
+	default:
+		return FaultUnknown
+	}
+}
+
+// handleError applies the error policy to an error raised while emulating.
+// A non-nil return means emulation must stop.
+func (gb *GameBoy) handleError(err error) error {
+	// Locking up is something real hardware does, the CPU stops but the timers and PPU keep
+	// running. Only the debugger gets to stop on it.
+	var illegal *cpu.IllegalOpcodeError
+	if errors.As(err, &illegal) && gb.cpu.Locked() && gb.Policy != PolicyBreak {
+		logger.GB.Warnf("%s", err)
+		return nil
+	}
+
+	switch gb.Policy {
+	case PolicyContinue:
+		if Classify(err) == FaultEmulator {
+			logger.Unimplemented.Warnf("%s", err)
+		} else {
+			logger.GB.Errorf("%s", err)
+		}
+		return nil
+
+	case PolicyBreak:
+		if gb.OnBreak != nil {
+			return gb.OnBreak(err)
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,64 @@
+/*
+		gemu - the gameboy emulator
+				<3 m0x
+	    __________________________
+	   |                          |
+	   | .----------------------. |
+	   | |  .----------------.  | |
+	   | |  |                |  | |
+	   | |))|                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  '----------------'  | |
+	   | |__GAME BOY____________/ |
+	   |          ________        |
+	   |    .    (Nintendo)       |
+	   |  _| |_   """"""""   .-.  |
+	   |-[_   _]-       .-. (   ) |
+	   |   |_|         (   ) '-'  |
+	   |    '           '-'   A   |
+	   |                 B        |
+	   |          ___   ___       |
+	   |         (___) (___)  ,., |
+	   |        select start ;:;: |
+	   |                    ,;:;' /
+	   |                   ,:;:'.'
+	   '-----------------------`
+*/
+package gb
+
+import "fmt"
+
+// EventKind is the type of an Event
+type EventKind int
+
+const (
+	EventLockup = EventKind(iota) // The CPU executed an unused opcode and locked up
+)
+
+func (k EventKind) String() string {
+	switch k {
+	case EventLockup:
+		return "lockup"
+	default:
+		return fmt.Sprintf("%d", int(k))
+	}
+}
+
+// Event is something that happened to the emulated hardware which a frontend or test harness
+// may want to know about, without having to poll the GameBoy for it.
+type Event struct {
+	Kind EventKind
+	Err  error // The error behind the event, if any
+}
+
+// emit sends an event to the events channel, without blocking the emulator if nobody is listening
+func (gb *GameBoy) emit(e Event) {
+	select {
+	case gb.Events <- e:
+	default:
+	}
+}
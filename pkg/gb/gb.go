@@ -31,14 +31,33 @@
 package gb
 
 import (
-	"fmt"
+	"errors"
+	"gemu/pkg/boot"
+	"gemu/pkg/cartridge"
 	"gemu/pkg/cpu"
+	"gemu/pkg/joypad"
+	"gemu/pkg/logger"
 	"gemu/pkg/mmu"
+	"gemu/pkg/ppu"
+	"gemu/pkg/symbols"
+	"time"
+)
+
+// Timing
+const (
+	// 4.194304 MHz was the highest freq the DMG could run at.
+	ClockSpeed = 4194304
+
+	// T-cycles in a single frame, from the start of one VBlank to the next
+	CyclesPerFrame = ppu.CyclesPerFrame
 
-	"github.com/veandco/go-sdl2/sdl"
-	"github.com/veandco/go-sdl2/ttf"
+	// ~59.7275 Hz
+	FrameRate = float64(ClockSpeed) / CyclesPerFrame
 )
 
+// frameDuration is how long a frame takes on real hardware, ~16.74ms
+const frameDuration = time.Second * CyclesPerFrame / ClockSpeed
+
 // GameBoy represents the GameBoy hardware
 type GameBoy struct {
 	// The heart of the Gameboy, the CPU.
@@ -51,80 +70,285 @@ type GameBoy struct {
 	// The MMU is responsible for mapping memory addresses to actual memory locations.
 	mmu *mmu.MMU
 
-	// nextFrame represents the SDL Texture channel that will be used by the renderer to display the Gameboy screen
-	nextFrame chan *sdl.Surface
+	// T-cycle count at which the current frame ends
+	frameEnd uint64
+
+	// nextFrame is where finished frames are sent to be displayed, if anything is displaying them
+	nextFrame chan *ppu.Frame
+
+	// tasks are run by Run between frames, see Do
+	tasks chan func()
+
+	// The path of the cartridge ROM, save state slots are kept next to it
+	romPath string
+
+	// Snapshots of the last few seconds for rewinding, nil unless EnableRewind was called
+	rewind *rewinder
+
+	// The buttons the player is holding, which the joypad sees from the start of the next frame
+	input joypad.Buttons
+
+	// How fast Run goes, see SetSpeed, SetPaused and AdvanceFrame
+	speed   float64
+	paused  bool
+	advance int
 
-	// Temp message to display while the PPU is not implemented
-	ppuWarning *sdl.Surface
+	// Policy decides what happens when emulation raises an error, see ErrorPolicy
+	Policy ErrorPolicy
+
+	// Lenient makes the CPU skip over unused opcodes instead of locking up, see cpu.CPU
+	Lenient bool
+
+	// Events receives notable hardware events, such as CPU lockups. It is optional, and events are
+	// dropped rather than blocking emulation when it's full.
+	Events chan Event
+
+	// OnBreak is called with the error when Policy is PolicyBreak, and is expected to hand control
+	// to a debugger until the user resumes. Returning an error stops emulation with that error.
+	OnBreak func(err error) error
+
+	// OnInput, if set, is called at the start of each frame with the frame's number and the buttons
+	// the player is holding, and returns the buttons the joypad sees. Movies record and replay input through it.
+	OnInput func(frame uint64, buttons joypad.Buttons) joypad.Buttons
+
+	// OnFrame, if set, is called with each frame as it's shown, including the frames rewinding steps
+	// back through. The frame is the PPU's own, so it has to be copied to be kept.
+	OnFrame func(frame *ppu.Frame)
+
+	// RTCStart is the time the cartridge's Real Time Clock is set to when the ROM is loaded.
+	// The clock runs on emulated time, so it doesn't depend on when or how fast the game is played.
+	RTCStart time.Duration
+
+	// Symbols are the ROM's labels, used by the debugger and traces. LoadROM picks up a .sym or .map
+	// file next to the ROM, if there is one and Symbols isn't already set.
+	Symbols *symbols.Table
 }
 
-// Run will start up the Gameboy Emulator
-func (gb *GameBoy) Run(gbStopped chan struct{}, stopGB chan struct{}) error {
-	emulating := true
+// Run will start up the Gameboy Emulator, emulating until stopGB is closed or emulation fails.
+// Nothing is emulated once it has returned, so that's when hooks such as OnFrame can be cleaned up.
+func (gb *GameBoy) Run(stopGB chan struct{}) error {
+	// Emulate a frame at a time, sleeping off whatever is left of the frame's real time.
+	// Deadlines are absolute, so rounding in the sleeps doesn't add up over time.
+	next := time.Now()
+	for {
+		select {
+		case <-stopGB:
+			return nil
+		default:
+		}
+		gb.runTasks()
+
+		// While paused only frame advances run, otherwise it just waits for something to do
+		if gb.paused && gb.advance == 0 {
+			time.Sleep(frameDuration)
+			next = time.Now()
+			continue
+		}
+		if gb.advance > 0 {
+			gb.advance--
+		}
 
-	// Stop channel monitoring
-	go func(stopped chan struct{}, stop chan struct{}) {
-		<-stop
-		emulating = false
-		close(stopped)
-	}(gbStopped, stopGB)
+		if gb.Rewinding() {
+			if err := gb.StepBack(); err != nil && !errors.Is(err, ErrRewindEmpty) {
+				return err
+			}
+		} else if err := gb.RunFrame(); err != nil {
+			return err
+		}
 
-	// GameBoy CPU Cycle
-	for emulating {
-		err := gb.cycle()
-		if err != nil {
-			fmt.Printf("[GB Cycle] Error: %s\n", err)
+		// Unlimited speed doesn't wait at all
+		if gb.speed == 0 {
+			next = time.Now()
+			continue
 		}
 
+		next = next.Add(time.Duration(float64(frameDuration) / gb.speed))
+		if wait := time.Until(next); wait > 0 {
+			time.Sleep(wait)
+		} else if wait < -frameDuration {
+			// We've fallen more than a frame behind, don't try to catch up
+			logger.Timing.Debugf("Running %s behind, skipping ahead", -wait)
+			next = time.Now()
+		}
 	}
+}
 
-	return nil
+// Do queues fn to run on the emulation goroutine between frames, for anything that touches
+// the machine while Run is running, such as saving and loading states
+func (gb *GameBoy) Do(fn func()) {
+	gb.tasks <- fn
 }
 
-// Init initializes the GameBoy, bringing subsystems online
-func (gb *GameBoy) Init(nextFrame chan *sdl.Surface) error {
-	// Setup a temporary warning message to indicate that the PPU is not yet implemented
-	font, err := ttf.OpenFont("./fonts/GameBoy.ttf", 20)
-	if err != nil {
-		return err
+// runTasks runs everything queued through Do
+func (gb *GameBoy) runTasks() {
+	for {
+		select {
+		case fn := <-gb.tasks:
+			fn()
+		default:
+			return
+		}
 	}
+}
 
-	gb.ppuWarning, err = font.RenderUTF8Blended("PPU NOT YET IMPLEMENTED", sdl.Color{R: 255, G: 255, B: 255, A: 255})
-	if err != nil {
+// RunFrame emulates a single frame's worth of T-cycles, then sends the frame to the renderer
+func (gb *GameBoy) RunFrame() error {
+	if err := gb.emulateFrame(); err != nil {
 		return err
 	}
-	font.Close()
+	gb.capture()
+	gb.present()
+	return nil
+}
+
+// emulateFrame emulates a single frame's worth of T-cycles, with the buttons the player is holding
+func (gb *GameBoy) emulateFrame() error {
+	buttons := gb.input
+	if gb.OnInput != nil {
+		buttons = gb.OnInput(gb.FrameCount(), buttons)
+	}
+	if gb.rewind != nil {
+		gb.rewind.record(gb.FrameCount(), buttons)
+	}
+	return gb.runFrame(buttons)
+}
+
+// runFrame emulates a single frame's worth of T-cycles with the buttons held.
+// Input only changes between frames, so a frame's input is all it takes to replay it exactly.
+func (gb *GameBoy) runFrame(buttons joypad.Buttons) error {
+	gb.mmu.Joypad().Press(buttons)
 
-	// Setup Gameboy subsystems
-	gb.cpu = new(cpu.CPU)
+	gb.frameEnd += CyclesPerFrame
+	for gb.cpu.Cycles() < gb.frameEnd {
+		if err := gb.cycle(); err != nil {
+			if err = gb.handleError(err); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// present sends the last finished frame to the renderer, and OnFrame.
+// It never blocks, if the renderer hasn't taken the last frame yet it misses this one.
+func (gb *GameBoy) present() {
+	if gb.OnFrame != nil {
+		gb.OnFrame(gb.mmu.PPU().Frame())
+	}
+	if gb.nextFrame != nil {
+		select {
+		case gb.nextFrame <- gb.Frame():
+		default:
+		}
+	}
+}
+
+// Init initializes the GameBoy, bringing subsystems online.
+// nextFrame may be nil when nothing is rendering, e.g. when debugging or running headless.
+func (gb *GameBoy) Init(nextFrame chan *ppu.Frame) error {
+	// Setup Gameboy subsystems <3
 	gb.mmu = new(mmu.MMU)
+	gb.mmu.Init()
+	gb.cpu = cpu.New(gb.mmu)
+	gb.cpu.Lenient = gb.Lenient
 	gb.nextFrame = nextFrame
+	gb.tasks = make(chan func(), 16)
+	gb.frameEnd = 0
+	gb.speed = 1
+
+	// The boot ROM runs first, until it unmaps itself
+	logger.GB.Infof("Loading boot ROM...")
+	gb.mmu.MapBootROM(boot.BootRom)
+
+	return nil
+}
+
+// LoadROM plugs the cartridge ROM at path into the GameBoy
+func (gb *GameBoy) LoadROM(path string) error {
+	cart, err := cartridge.Load(path)
+	if err != nil {
+		return err
+	}
 
-	// Init Gameboy subsystems <3
-	gb.cpu.Init(gb.mmu)
+	gb.mmu.LoadCartridge(cart)
+	gb.romPath = path
+	if rtc := cart.RTC(); rtc != nil {
+		rtc.Set(gb.RTCStart)
+	}
 
+	if gb.Symbols == nil {
+		syms, symPath, err := symbols.LoadBeside(path)
+		if err != nil {
+			logger.GB.Warnf("Failed to load symbols from %s - %s", symPath, err)
+		} else if syms != nil {
+			logger.GB.Infof("Loaded %d symbols from %s", syms.Len(), symPath)
+			gb.Symbols = syms
+		}
+	}
 	return nil
 }
 
+// SkipBootROM starts the GameBoy in the state the boot ROM leaves it in, at the cartridge entry point.
+// Reference traces and test ROMs expect this state.
+func (gb *GameBoy) SkipBootROM() {
+	gb.cpu.SetRegisters(cpu.PostBoot)
+	gb.mmu.Write(mmu.BOOT, 0x01)
+	gb.mmu.Write(ppu.LCDC, 0x91)
+	gb.mmu.Write(ppu.BGP, 0xFC)
+}
+
+// Frame returns a copy of the last frame the PPU finished
+func (gb *GameBoy) Frame() *ppu.Frame {
+	f := new(ppu.Frame)
+	*f = *gb.mmu.PPU().Frame()
+	return f
+}
+
+// FrameCount returns how many frames have been emulated since power on
+func (gb *GameBoy) FrameCount() uint64 {
+	return gb.frameEnd / CyclesPerFrame
+}
+
+// SetButtons sets the buttons the player is holding, from the start of the next frame.
+// While Run is running, this has to be called through Do.
+func (gb *GameBoy) SetButtons(buttons joypad.Buttons) {
+	gb.input = buttons
+}
+
+// CPU returns the GameBoy's CPU
+func (gb *GameBoy) CPU() *cpu.CPU {
+	return gb.cpu
+}
+
+// MMU returns the GameBoy's MMU
+func (gb *GameBoy) MMU() *mmu.MMU {
+	return gb.mmu
+}
+
+// Step runs a single CPU instruction, along with the rest of the hardware.
+// Errors are returned as is, without applying Policy.
+func (gb *GameBoy) Step() error {
+	return gb.cycle()
+}
+
+// Locked reports if the CPU has locked up. The rest of the hardware keeps running while it is.
+func (gb *GameBoy) Locked() bool {
+	return gb.cpu.Locked()
+}
+
 // Cycle represents a single GameBoy CPU/Emulation Cycle (Fetch/Decode/Execute)
 func (gb *GameBoy) cycle() error {
 	// Fetch, Decode, and Execute
 	err := gb.cpu.Step()
 
 	if err != nil {
+		var illegal *cpu.IllegalOpcodeError
+		if errors.As(err, &illegal) && gb.cpu.Locked() {
+			gb.emit(Event{Kind: EventLockup, Err: err})
+		}
 		return err
 	}
 
-	// WARNING: This is a blocking operation !!
-	// As long as the emulator doesn't run too fast, it shouldn't matter.
-	select {
-	case gb.nextFrame <- gb.ppuWarning:
-		//fmt.Println("SENT FRAME")
-	default:
-		//fmt.Println("FRAME CHAN BLOCK")
-	}
-	//gb.nextFrame <- gb.ppuWarning
-
 	// TODO: other stuff will happen here, of course...
 
 	return nil
@@ -0,0 +1,182 @@
+/*
+		gemu - the gameboy emulator
+				<3 m0x
+	    __________________________
+	   |                          |
+	   | .----------------------. |
+	   | |  .----------------.  | |
+	   | |  |                |  | |
+	   | |))|                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  '----------------'  | |
+	   | |__GAME BOY____________/ |
+	   |          ________        |
+	   |    .    (Nintendo)       |
+	   |  _| |_   """"""""   .-.  |
+	   |-[_   _]-       .-. (   ) |
+	   |   |_|         (   ) '-'  |
+	   |    '           '-'   A   |
+	   |                 B        |
+	   |          ___   ___       |
+	   |         (___) (___)  ,., |
+	   |        select start ;:;: |
+	   |                    ,;:;' /
+	   |                   ,:;:'.'
+	   '-----------------------`
+*/
+package gb
+
+import (
+	"bytes"
+	"errors"
+	"gemu/pkg/joypad"
+	"gemu/pkg/logger"
+	"gemu/pkg/rewind"
+	"gemu/pkg/savestate"
+)
+
+// ErrRewindEmpty is returned when rewinding past the oldest snapshot
+var ErrRewindEmpty = errors.New("nothing left to rewind")
+
+// rewinder records snapshots as frames are emulated, see EnableRewind
+type rewinder struct {
+	buf      *rewind.Buffer
+	interval uint64 // Frames between snapshots
+	active   bool   // Is Run stepping backwards?
+	snapshot bytes.Buffer
+
+	// The buttons each frame was emulated with, from frame inputStart on. Replaying from a
+	// snapshot needs the input the frames had at the time, not the buttons held now.
+	inputs     []joypad.Buttons
+	inputStart uint64
+}
+
+// record notes the buttons a frame is emulated with
+func (r *rewinder) record(frame uint64, buttons joypad.Buttons) {
+	// Frames after a rewound frame are from another timeline, and after a jump nothing before it is any use
+	if frame < r.inputStart || frame > r.inputStart+uint64(len(r.inputs)) {
+		r.inputs, r.inputStart = r.inputs[:0], frame
+	}
+	r.inputs = append(r.inputs[:frame-r.inputStart], buttons)
+
+	// Only the frames since the oldest snapshot are needed, they're dropped in bulk to save copying
+	keep := uint64(r.buf.Cap()+1) * r.interval
+	if uint64(len(r.inputs)) > 2*keep {
+		drop := uint64(len(r.inputs)) - keep
+		r.inputs = append(r.inputs[:0], r.inputs[drop:]...)
+		r.inputStart += drop
+	}
+}
+
+// input returns the buttons a frame was emulated with
+func (r *rewinder) input(frame uint64) (joypad.Buttons, bool) {
+	if frame < r.inputStart || frame >= r.inputStart+uint64(len(r.inputs)) {
+		return 0, false
+	}
+	return r.inputs[frame-r.inputStart], true
+}
+
+// reset forgets the snapshots and input, when they're from another timeline
+func (r *rewinder) reset() {
+	r.buf.Reset()
+	r.inputs, r.inputStart = r.inputs[:0], 0
+}
+
+// EnableRewind starts recording the last seconds of emulation, taking a snapshot every interval frames.
+// A larger interval takes less memory and time while playing, but stepping back a frame has to
+// replay up to interval-1 frames from the snapshot before it. Zero seconds turns rewinding off.
+func (gb *GameBoy) EnableRewind(seconds float64, interval int) {
+	if seconds <= 0 {
+		gb.rewind = nil
+		return
+	}
+	if interval < 1 {
+		interval = 1
+	}
+
+	size := int(seconds*FrameRate) / interval
+	gb.rewind = &rewinder{buf: rewind.New(size), interval: uint64(interval)}
+	logger.GB.Infof("Rewind enabled, %d snapshots every %d frames", size, interval)
+}
+
+// SetRewinding starts or stops rewinding, Run steps backwards a frame at a time while it's set.
+// While Run is running, this has to be called through Do.
+func (gb *GameBoy) SetRewinding(on bool) {
+	if gb.rewind != nil {
+		gb.rewind.active = on
+	}
+}
+
+// Rewinding reports if Run is stepping backwards
+func (gb *GameBoy) Rewinding() bool {
+	return gb.rewind != nil && gb.rewind.active
+}
+
+// capture takes a snapshot for rewinding, if one is due
+func (gb *GameBoy) capture() {
+	r := gb.rewind
+	if r == nil || gb.FrameCount()%r.interval != 0 {
+		return
+	}
+
+	r.snapshot.Reset()
+	if err := gb.save(&r.snapshot, false); err != nil {
+		logger.GB.Errorf("Failed to take rewind snapshot - %s", err)
+		return
+	}
+	if err := r.buf.Push(gb.FrameCount(), r.snapshot.Bytes()); err != nil {
+		logger.GB.Errorf("Failed to store rewind snapshot - %s", err)
+	}
+}
+
+// StepBack rewinds the GameBoy by a single frame and sends that frame to the renderer.
+// ErrRewindEmpty is returned once it reaches the oldest snapshot.
+func (gb *GameBoy) StepBack() error {
+	r := gb.rewind
+	if r == nil || gb.FrameCount() == 0 {
+		return ErrRewindEmpty
+	}
+	target := gb.FrameCount() - 1
+
+	// Find the newest snapshot at or before the target frame
+	var state []byte
+	for {
+		frame, s, ok := r.buf.Latest()
+		if !ok {
+			return ErrRewindEmpty
+		}
+		if frame <= target {
+			state = s
+			break
+		}
+		if err := r.buf.Pop(); err != nil {
+			return err
+		}
+	}
+
+	d, err := savestate.NewDecoder(bytes.NewReader(state))
+	if err != nil {
+		return err
+	}
+	if err := gb.restore(d); err != nil {
+		return err
+	}
+
+	// Play forward from the snapshot to the target frame with the input they had, without showing
+	// the frames in between. They've already been through OnInput, so it isn't called again.
+	for gb.FrameCount() < target {
+		buttons, ok := r.input(gb.FrameCount())
+		if !ok {
+			logger.GB.Warnf("No input recorded for frame %d, rewinding won't be exact", gb.FrameCount())
+		}
+		if err := gb.runFrame(buttons); err != nil {
+			return err
+		}
+	}
+	gb.present()
+	return nil
+}
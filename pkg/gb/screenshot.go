@@ -0,0 +1,124 @@
+/*
+		gemu - the gameboy emulator
+				<3 m0x
+	    __________________________
+	   |                          |
+	   | .----------------------. |
+	   | |  .----------------.  | |
+	   | |  |                |  | |
+	   | |))|                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  '----------------'  | |
+	   | |__GAME BOY____________/ |
+	   |          ________        |
+	   |    .    (Nintendo)       |
+	   |  _| |_   """"""""   .-.  |
+	   |-[_   _]-       .-. (   ) |
+	   |   |_|         (   ) '-'  |
+	   |    '           '-'   A   |
+	   |                 B        |
+	   |          ___   ___       |
+	   |         (___) (___)  ,., |
+	   |        select start ;:;: |
+	   |                    ,;:;' /
+	   |                   ,:;:'.'
+	   '-----------------------`
+*/
+package gb
+
+import (
+	"errors"
+	"fmt"
+	"gemu/pkg/logger"
+	"gemu/pkg/ppu"
+	"image"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// MaxScreenshotScale is the biggest screenshots can be scaled up, as a multiple of the screen
+const MaxScreenshotScale = 8
+
+// Screenshot writes the last frame as a PNG next to the ROM, coloured with p and scaled up by a whole
+// multiple, returning the path it was written to. It's named by the ROM's title and the time.
+func (gb *GameBoy) Screenshot(p ppu.Palettes, scale int) (string, error) {
+	base := gb.CaptureName()
+
+	// Two screenshots in the same second get numbered, rather than one replacing the other
+	path := base + ".png"
+	for n := 2; ; n++ {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if errors.Is(err, os.ErrExist) {
+			path = fmt.Sprintf("%s-%d.png", base, n)
+			continue
+		} else if err != nil {
+			return "", err
+		}
+
+		if err := gb.WriteScreenshot(f, p, scale); err != nil {
+			f.Close()
+			os.Remove(path)
+			return "", err
+		}
+		if err := f.Close(); err != nil {
+			os.Remove(path)
+			return "", err
+		}
+
+		logger.GB.Infof("Saved screenshot to %s", path)
+		return path, nil
+	}
+}
+
+// WriteScreenshot writes the last frame to w as a PNG, coloured with p and scaled up by a whole multiple
+func (gb *GameBoy) WriteScreenshot(w io.Writer, p ppu.Palettes, scale int) error {
+	if scale < 1 || scale > MaxScreenshotScale {
+		return fmt.Errorf("screenshot scale %d isn't 1-%d", scale, MaxScreenshotScale)
+	}
+	return png.Encode(w, upscale(gb.mmu.PPU().Frame().ImagePalettes(p), scale))
+}
+
+// CaptureName returns a name for screenshots and recordings, without an extension. They're kept next
+// to the ROM, named by its title and the time.
+func (gb *GameBoy) CaptureName() string {
+	title := "gemu"
+	if cart := gb.mmu.Cartridge(); cart != nil && cart.Header.Title != "" {
+		title = cart.Header.Title
+	}
+
+	// Titles are ASCII, but not all of it is safe in a file name
+	title = strings.Map(func(r rune) rune {
+		if r >= 'A' && r <= 'Z' || r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-' {
+			return r
+		}
+		return '_'
+	}, strings.TrimSpace(title))
+
+	return filepath.Join(filepath.Dir(gb.romPath), title+"_"+time.Now().Format("20060102-150405"))
+}
+
+// upscale scales an image up by a whole multiple, keeping the pixels sharp
+func upscale(img *image.RGBA, scale int) *image.RGBA {
+	if scale == 1 {
+		return img
+	}
+
+	b := img.Bounds()
+	out := image.NewRGBA(image.Rect(0, 0, b.Dx()*scale, b.Dy()*scale))
+	for y := 0; y < out.Rect.Dy(); y++ {
+		row := out.Pix[y*out.Stride:]
+		src := img.Pix[(y/scale)*img.Stride:]
+		for x := 0; x < out.Rect.Dx(); x++ {
+			copy(row[x*4:x*4+4], src[(x/scale)*4:])
+		}
+	}
+	return out
+}
@@ -0,0 +1,68 @@
+/*
+		gemu - the gameboy emulator
+				<3 m0x
+	    __________________________
+	   |                          |
+	   | .----------------------. |
+	   | |  .----------------.  | |
+	   | |  |                |  | |
+	   | |))|                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  '----------------'  | |
+	   | |__GAME BOY____________/ |
+	   |          ________        |
+	   |    .    (Nintendo)       |
+	   |  _| |_   """"""""   .-.  |
+	   |-[_   _]-       .-. (   ) |
+	   |   |_|         (   ) '-'  |
+	   |    '           '-'   A   |
+	   |                 B        |
+	   |          ___   ___       |
+	   |         (___) (___)  ,., |
+	   |        select start ;:;: |
+	   |                    ,;:;' /
+	   |                   ,:;:'.'
+	   '-----------------------`
+*/
+package gb
+
+// SetSpeed sets how fast Run emulates, as a multiple of the real hardware's speed, 0 for as fast
+// as possible. Sound is muted at any speed other than 1x.
+// While Run is running, this has to be called through Do.
+func (gb *GameBoy) SetSpeed(multiplier float64) {
+	if multiplier < 0 {
+		multiplier = 0
+	}
+	gb.speed = multiplier
+	gb.mmu.APU().SetMuted(multiplier != 1 || gb.paused)
+}
+
+// Speed returns the speed multiplier Run emulates at, 0 for as fast as possible
+func (gb *GameBoy) Speed() float64 {
+	return gb.speed
+}
+
+// SetPaused pauses or resumes Run.
+// While Run is running, this has to be called through Do.
+func (gb *GameBoy) SetPaused(paused bool) {
+	gb.paused = paused
+	gb.advance = 0
+	gb.mmu.APU().SetMuted(gb.speed != 1 || paused)
+}
+
+// Paused reports if Run is paused
+func (gb *GameBoy) Paused() bool {
+	return gb.paused
+}
+
+// AdvanceFrame makes a paused Run emulate a single frame.
+// While Run is running, this has to be called through Do.
+func (gb *GameBoy) AdvanceFrame() {
+	if gb.paused {
+		gb.advance++
+	}
+}
@@ -0,0 +1,190 @@
+/*
+		gemu - the gameboy emulator
+				<3 m0x
+	    __________________________
+	   |                          |
+	   | .----------------------. |
+	   | |  .----------------.  | |
+	   | |  |                |  | |
+	   | |))|                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  '----------------'  | |
+	   | |__GAME BOY____________/ |
+	   |          ________        |
+	   |    .    (Nintendo)       |
+	   |  _| |_   """"""""   .-.  |
+	   |-[_   _]-       .-. (   ) |
+	   |   |_|         (   ) '-'  |
+	   |    '           '-'   A   |
+	   |                 B        |
+	   |          ___   ___       |
+	   |         (___) (___)  ,., |
+	   |        select start ;:;: |
+	   |                    ,;:;' /
+	   |                   ,:;:'.'
+	   '-----------------------`
+*/
+package gb
+
+import (
+	"bytes"
+	"fmt"
+	"gemu/pkg/logger"
+	"gemu/pkg/ppu"
+	"gemu/pkg/savestate"
+	"image"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Slots is the number of numbered save state slots
+const Slots = 9
+
+// SaveState writes the whole machine state to w, along with a thumbnail of the last frame
+func (gb *GameBoy) SaveState(w io.Writer) error {
+	return gb.save(w, true)
+}
+
+// save writes the machine state, the thumbnail is left out of states that are only kept in memory
+func (gb *GameBoy) save(w io.Writer, thumbnail bool) error {
+	e := savestate.NewEncoder(w)
+	e.Section("GB  ", gb.frameEnd)
+	gb.cpu.SaveState(e)
+	gb.mmu.SaveState(e)
+	if !thumbnail {
+		return e.Err()
+	}
+
+	var thumb bytes.Buffer
+	if err := png.Encode(&thumb, gb.mmu.PPU().Frame().Image(ppu.GreyPalette)); err != nil {
+		return err
+	}
+	e.Section("THMB", thumb.Bytes())
+
+	return e.Err()
+}
+
+// LoadState restores the machine state written by SaveState. The state is checked before
+// anything is changed, and if restoring still fails the GameBoy is left as it was.
+func (gb *GameBoy) LoadState(r io.Reader) error {
+	d, err := savestate.NewDecoder(r)
+	if err != nil {
+		return err
+	}
+	if err := gb.mmu.Check(d); err != nil {
+		return err
+	}
+
+	var backup bytes.Buffer
+	if err := gb.save(&backup, false); err != nil {
+		return err
+	}
+
+	if err := gb.restore(d); err != nil {
+		if undo, derr := savestate.NewDecoder(&backup); derr == nil {
+			gb.restore(undo)
+		}
+		return err
+	}
+
+	// The rewind snapshots are from another timeline now
+	if gb.rewind != nil {
+		gb.rewind.reset()
+	}
+	return nil
+}
+
+func (gb *GameBoy) restore(d *savestate.Decoder) error {
+	if err := d.Section("GB  ", &gb.frameEnd); err != nil {
+		return err
+	}
+	if err := gb.cpu.LoadState(d); err != nil {
+		return err
+	}
+	return gb.mmu.LoadState(d)
+}
+
+// Thumbnail returns the thumbnail embedded in a save state, without loading it
+func Thumbnail(r io.Reader) (image.Image, error) {
+	d, err := savestate.NewDecoder(r)
+	if err != nil {
+		return nil, err
+	}
+	data, ok := d.Bytes("THMB")
+	if !ok {
+		return nil, fmt.Errorf("save state has no thumbnail")
+	}
+	return png.Decode(bytes.NewReader(data))
+}
+
+// SlotPath returns the file a numbered save state slot is kept in, next to the ROM
+func (gb *GameBoy) SlotPath(slot int) string {
+	return fmt.Sprintf("%s.ss%d", strings.TrimSuffix(gb.romPath, filepath.Ext(gb.romPath)), slot)
+}
+
+// SaveSlot saves the machine state to a numbered slot
+func (gb *GameBoy) SaveSlot(slot int) error {
+	if err := gb.checkSlot(slot); err != nil {
+		return err
+	}
+
+	// Write to a temporary file first, so a failed save doesn't clobber the slot
+	path := gb.SlotPath(slot)
+	f, err := os.Create(path + ".tmp")
+	if err != nil {
+		return err
+	}
+	if err := gb.SaveState(f); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		return err
+	}
+
+	logger.GB.Infof("Saved state to slot %d (%s)", slot, path)
+	return nil
+}
+
+// LoadSlot restores the machine state from a numbered slot
+func (gb *GameBoy) LoadSlot(slot int) error {
+	if err := gb.checkSlot(slot); err != nil {
+		return err
+	}
+
+	path := gb.SlotPath(slot)
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := gb.LoadState(f); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	logger.GB.Infof("Loaded state from slot %d (%s)", slot, path)
+	return nil
+}
+
+func (gb *GameBoy) checkSlot(slot int) error {
+	if slot < 1 || slot > Slots {
+		return fmt.Errorf("save state slot %d doesn't exist, slots are 1-%d", slot, Slots)
+	}
+	if gb.romPath == "" {
+		return fmt.Errorf("save state slots need a ROM to be loaded")
+	}
+	return nil
+}
@@ -29,3 +29,36 @@
 	   '-----------------------`
 */
 package interrupt
+
+/*	https://gbdev.io/pandocs/Interrupts.html
+
+Each interrupt source has a bit in the IF (requested) and IE (enabled) registers.
+When several are pending at once, the lowest bit has the highest priority.
+
+Bit	Interrupt	Handler
+0	VBlank		0x40
+1	LCD STAT	0x48
+2	Timer		0x50
+3	Serial		0x58
+4	Joypad		0x60
+*/
+
+// Register addresses
+const (
+	IF = uint16(0xFF0F) // Interrupt Flag, requested interrupts
+	IE = uint16(0xFFFF) // Interrupt Enable, enabled interrupts
+)
+
+// Flag is an interrupt source's bit in the IF and IE registers
+type Flag uint8
+
+const (
+	VBlank  = Flag(1 << iota) // The PPU entered VBlank
+	LCDStat                   // One of the STAT conditions was met
+	Timer                     // TIMA overflowed
+	Serial                    // A serial transfer finished
+	Joypad                    // A button was pressed
+)
+
+// Request is how hardware raises an interrupt, by setting its flag in IF
+type Request func(f Flag)
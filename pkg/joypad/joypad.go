@@ -29,3 +29,156 @@
 	   '-----------------------`
 */
 package joypad
+
+import (
+	"fmt"
+	"gemu/pkg/interrupt"
+	"gemu/pkg/savestate"
+	"strings"
+)
+
+/*	https://gbdev.io/pandocs/Joypad_Input.html
+
+The 8 buttons are wired up as a 2x4 matrix. Bits 4 and 5 of P1 select which half of it is read
+back in bits 0-3, where a 0 means the button is pressed. When a selected line goes from 1 to 0,
+the Joypad interrupt is requested.
+
+Addr	Name	Description
+FF00	P1		Joypad - bit 5 select action buttons, bit 4 select d-pad, bits 0-3 buttons (0 = pressed)
+
+Bit	D-pad (bit 4 = 0)	Buttons (bit 5 = 0)
+0	Right				A
+1	Left				B
+2	Up					Select
+3	Down				Start
+*/
+
+// P1 is the joypad register
+const P1 = uint16(0xFF00)
+
+// Buttons is a set of pressed buttons, the d-pad in the lower nibble and the action buttons in the upper
+type Buttons uint8
+
+const (
+	Right = Buttons(1 << iota)
+	Left
+	Up
+	Down
+	A
+	B
+	Select
+	Start
+)
+
+// buttonNames are the names of the buttons, in bit order
+var buttonNames = [8]string{"Right", "Left", "Up", "Down", "A", "B", "Select", "Start"}
+
+func (b Buttons) String() string {
+	var names []string
+	for i, name := range buttonNames {
+		if b&(1<<i) != 0 {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return "none"
+	}
+	return strings.Join(names, "+")
+}
+
+// ParseButtons parses buttons as written by Buttons.String, e.g. "A+Start"
+func ParseButtons(s string) (Buttons, error) {
+	var b Buttons
+	if s == "none" || s == "" {
+		return b, nil
+	}
+next:
+	for _, name := range strings.Split(s, "+") {
+		for i, n := range buttonNames {
+			if strings.EqualFold(name, n) {
+				b |= 1 << i
+				continue next
+			}
+		}
+		return 0, fmt.Errorf("unknown button %q", name)
+	}
+	return b, nil
+}
+
+// Joypad is the joypad, as seen through P1
+type Joypad struct {
+	selected uint8 // Bits 4-5 of P1
+	buttons  Buttons
+
+	request interrupt.Request
+}
+
+// Init resets the joypad, interrupts are raised through request
+func (j *Joypad) Init(request interrupt.Request) {
+	*j = Joypad{selected: 0x30, request: request}
+}
+
+// Read reads P1
+func (j *Joypad) Read() uint8 {
+	// Unused bits read as 1
+	return 0xC0 | j.selected | j.lines()
+}
+
+// Write writes P1, only the select bits are writable
+func (j *Joypad) Write(value uint8) {
+	before := j.lines()
+	j.selected = value & 0x30
+	j.check(before)
+}
+
+// Press sets which buttons are held down
+func (j *Joypad) Press(buttons Buttons) {
+	before := j.lines()
+	j.buttons = buttons
+	j.check(before)
+}
+
+// Buttons returns the buttons being held down
+func (j *Joypad) Buttons() Buttons {
+	return j.buttons
+}
+
+// lines returns bits 0-3 of P1, for the buttons in the selected half of the matrix
+func (j *Joypad) lines() uint8 {
+	lines := uint8(0x0F)
+	if j.selected&0x10 == 0 {
+		lines &^= uint8(j.buttons) & 0x0F
+	}
+	if j.selected&0x20 == 0 {
+		lines &^= uint8(j.buttons>>4) & 0x0F
+	}
+	return lines
+}
+
+// check requests the Joypad interrupt if any line fell since before
+func (j *Joypad) check(before uint8) {
+	if before&^j.lines() != 0 {
+		j.request(interrupt.Joypad)
+	}
+}
+
+// state is the joypad's section of a save state
+type state struct {
+	Selected uint8
+	Buttons  Buttons
+}
+
+// SaveState writes the joypad's state
+func (j *Joypad) SaveState(e *savestate.Encoder) {
+	e.Section("JOYP", state{Selected: j.selected, Buttons: j.buttons})
+}
+
+// LoadState restores the joypad's state
+func (j *Joypad) LoadState(d *savestate.Decoder) error {
+	var s state
+	if err := d.Section("JOYP", &s); err != nil {
+		return err
+	}
+	j.selected, j.buttons = s.Selected, s.Buttons
+	return nil
+}
@@ -0,0 +1,230 @@
+/*
+		gemu - the gameboy emulator
+				<3 m0x
+	    __________________________
+	   |                          |
+	   | .----------------------. |
+	   | |  .----------------.  | |
+	   | |  |                |  | |
+	   | |))|                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  '----------------'  | |
+	   | |__GAME BOY____________/ |
+	   |          ________        |
+	   |    .    (Nintendo)       |
+	   |  _| |_   """"""""   .-.  |
+	   |-[_   _]-       .-. (   ) |
+	   |   |_|         (   ) '-'  |
+	   |    '           '-'   A   |
+	   |                 B        |
+	   |          ___   ___       |
+	   |         (___) (___)  ,., |
+	   |        select start ;:;: |
+	   |                    ,;:;' /
+	   |                   ,:;:'.'
+	   '-----------------------`
+*/
+package logger
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Level is the verbosity of a log message, higher levels are more verbose.
+type Level int
+
+const (
+	Off   = Level(iota) // Nothing is logged
+	Error               // Something went wrong
+	Warn                // Something looks wrong, but emulation can continue
+	Info                // General status messages
+	Debug               // Detailed status messages
+	Trace               // Very noisy, per access/instruction messages
+)
+
+func (l Level) String() string {
+	switch l {
+	case Off:
+		return "off"
+	case Error:
+		return "error"
+	case Warn:
+		return "warn"
+	case Info:
+		return "info"
+	case Debug:
+		return "debug"
+	case Trace:
+		return "trace"
+	default:
+		return fmt.Sprintf("%d", int(l))
+	}
+}
+
+// Category is the emulator subsystem a log message belongs to. Each category has its own level.
+type Category int
+
+const (
+	GB            = Category(iota) // GameBoy emulation loop
+	CPU                            // Instruction execution
+	Unimplemented                  // Unimplemented and unused opcodes
+	Stack                          // Stack pushes and pops
+	MMU                            // Memory reads and writes
+	Timing                         // CPU clock and emulation speed
+	Cartridge                      // Cartridge loading and banking
+	numCategories
+)
+
+func (c Category) String() string {
+	switch c {
+	case GB:
+		return "gb"
+	case CPU:
+		return "cpu"
+	case Unimplemented:
+		return "unimplemented"
+	case Stack:
+		return "stack"
+	case MMU:
+		return "mmu"
+	case Timing:
+		return "timing"
+	case Cartridge:
+		return "cartridge"
+	default:
+		return fmt.Sprintf("%d", int(c))
+	}
+}
+
+var (
+	// levels holds the current level of each category
+	levels [numCategories]Level
+
+	// out is where log messages are written to, guarded by mu since the emulator and renderer
+	// both run in their own goroutines.
+	out io.Writer = os.Stderr
+	mu  sync.Mutex
+)
+
+// Only warnings and errors are logged by default, the noisy stuff has to be asked for
+func init() {
+	SetAll(Warn)
+}
+
+// SetOutput sets the destination for all log messages
+func SetOutput(w io.Writer) {
+	mu.Lock()
+	out = w
+	mu.Unlock()
+}
+
+// SetLevel sets the level of a single category
+func SetLevel(c Category, l Level) {
+	levels[c] = l
+}
+
+// SetAll sets the level of every category
+func SetAll(l Level) {
+	for c := range levels {
+		levels[c] = l
+	}
+}
+
+// Parse configures category levels from a comma separated spec, as given on the command line.
+// A bare level applies to every category, and category=level applies to a single category.
+// Entries are applied left to right, so "info,mmu=trace" logs everything at info and MMU accesses at trace.
+func Parse(spec string) error {
+	if spec == "" {
+		return nil
+	}
+
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		cat, lvl := "all", entry
+		if i := strings.IndexByte(entry, '='); i >= 0 {
+			cat, lvl = entry[:i], entry[i+1:]
+		}
+
+		l, err := ParseLevel(lvl)
+		if err != nil {
+			return err
+		}
+
+		if cat == "all" || cat == "*" {
+			SetAll(l)
+			continue
+		}
+		c, err := ParseCategory(cat)
+		if err != nil {
+			return err
+		}
+		SetLevel(c, l)
+	}
+
+	return nil
+}
+
+// ParseLevel returns the Level with the given name
+func ParseLevel(name string) (Level, error) {
+	for l := Off; l <= Trace; l++ {
+		if strings.EqualFold(name, l.String()) {
+			return l, nil
+		}
+	}
+	return Off, fmt.Errorf("unknown log level %q", name)
+}
+
+// ParseCategory returns the Category with the given name
+func ParseCategory(name string) (Category, error) {
+	for c := Category(0); c < numCategories; c++ {
+		if strings.EqualFold(name, c.String()) {
+			return c, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown log category %q", name)
+}
+
+// Enabled reports if messages of the given level will be logged for this category.
+// Hot paths should check this before building a message, so a disabled category costs a single compare.
+func (c Category) Enabled(l Level) bool {
+	return l <= levels[c]
+}
+
+// Logf writes a message to the log, if the category is enabled for the given level
+func (c Category) Logf(l Level, format string, args ...interface{}) {
+	if !c.Enabled(l) {
+		return
+	}
+
+	msg := fmt.Sprintf(format, args...)
+	mu.Lock()
+	fmt.Fprintf(out, "[%s] %s: %s\n", strings.ToUpper(c.String()), l, msg)
+	mu.Unlock()
+}
+
+// Errorf logs a message at the Error level
+func (c Category) Errorf(format string, args ...interface{}) { c.Logf(Error, format, args...) }
+
+// Warnf logs a message at the Warn level
+func (c Category) Warnf(format string, args ...interface{}) { c.Logf(Warn, format, args...) }
+
+// Infof logs a message at the Info level
+func (c Category) Infof(format string, args ...interface{}) { c.Logf(Info, format, args...) }
+
+// Debugf logs a message at the Debug level
+func (c Category) Debugf(format string, args ...interface{}) { c.Logf(Debug, format, args...) }
+
+// Tracef logs a message at the Trace level
+func (c Category) Tracef(format string, args ...interface{}) { c.Logf(Trace, format, args...) }
@@ -0,0 +1,82 @@
+/*
+		gemu - the gameboy emulator
+				<3 m0x
+	    __________________________
+	   |                          |
+	   | .----------------------. |
+	   | |  .----------------.  | |
+	   | |  |                |  | |
+	   | |))|                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  '----------------'  | |
+	   | |__GAME BOY____________/ |
+	   |          ________        |
+	   |    .    (Nintendo)       |
+	   |  _| |_   """"""""   .-.  |
+	   |-[_   _]-       .-. (   ) |
+	   |   |_|         (   ) '-'  |
+	   |    '           '-'   A   |
+	   |                 B        |
+	   |          ___   ___       |
+	   |         (___) (___)  ,., |
+	   |        select start ;:;: |
+	   |                    ,;:;' /
+	   |                   ,:;:'.'
+	   '-----------------------`
+*/
+package mmu
+
+/*	https://gbdev.io/pandocs/OAM_DMA_Transfer.html
+
+Writing to DMA (FF46) copies 160 bytes from XX00-XX9F to OAM (FE00-FE9F), where XX is the value written.
+The copy takes 160 M-cycles, one byte per M-cycle.
+
+TODO: The CPU can only access HRAM while a transfer is running.
+*/
+
+// DMA is the OAM DMA source and start register
+const DMA = uint16(0xFF46)
+
+// dmaLength is the number of bytes copied by an OAM DMA transfer
+const dmaLength = 0xA0
+
+// dma is the state of an OAM DMA transfer
+type dma struct {
+	active bool
+	source uint8 // Upper byte of the source address, as written to FF46
+	index  uint16
+	cycles int // T-cycles left over from the last byte copied
+}
+
+// startDMA starts a new OAM DMA transfer, restarting any transfer already running
+func (mmu *MMU) startDMA(source uint8) {
+	mmu.dma = dma{active: true, source: source}
+}
+
+// tickDMA advances a running OAM DMA transfer by the given number of T-cycles
+func (mmu *MMU) tickDMA(cycles int) {
+	if !mmu.dma.active {
+		return
+	}
+
+	mmu.dma.cycles += cycles
+	for mmu.dma.cycles >= 4 && mmu.dma.active {
+		mmu.dma.cycles -= 4
+
+		src := uint16(mmu.dma.source)<<8 | mmu.dma.index
+		if src >= 0xE000 {
+			// Sources past WRAM read from the Echo RAM mirror
+			src -= 0x2000
+		}
+		mmu.memory[0xFE00+mmu.dma.index] = mmu.Read(src)
+
+		mmu.dma.index++
+		if mmu.dma.index == dmaLength {
+			mmu.dma.active = false
+		}
+	}
+}
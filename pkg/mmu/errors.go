@@ -0,0 +1,49 @@
+/*
+		gemu - the gameboy emulator
+				<3 m0x
+	    __________________________
+	   |                          |
+	   | .----------------------. |
+	   | |  .----------------.  | |
+	   | |  |                |  | |
+	   | |))|                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  '----------------'  | |
+	   | |__GAME BOY____________/ |
+	   |          ________        |
+	   |    .    (Nintendo)       |
+	   |  _| |_   """"""""   .-.  |
+	   |-[_   _]-       .-. (   ) |
+	   |   |_|         (   ) '-'  |
+	   |    '           '-'   A   |
+	   |                 B        |
+	   |          ___   ___       |
+	   |         (___) (___)  ,., |
+	   |        select start ;:;: |
+	   |                    ,;:;' /
+	   |                   ,:;:'.'
+	   '-----------------------`
+*/
+package mmu
+
+import "fmt"
+
+// AccessError is reported when memory is accessed in a way the GameBoy doesn't allow,
+// such as writing to the prohibited Echo RAM or the unusable region after OAM.
+type AccessError struct {
+	Addr   uint16    // The address that was accessed
+	Region MemRegion // The region Addr maps to
+	Write  bool      // Was the access a write?
+	Value  uint8     // The value being written, if Write is set
+}
+
+func (e *AccessError) Error() string {
+	if e.Write {
+		return fmt.Sprintf("invalid memory access: can't write 0x%02X to %s[0x%04X]", e.Value, e.Region, e.Addr)
+	}
+	return fmt.Sprintf("invalid memory access: can't read from %s[0x%04X]", e.Region, e.Addr)
+}
@@ -30,7 +30,18 @@
 */
 package mmu
 
-import "fmt"
+import (
+	"fmt"
+	"gemu/pkg/apu"
+	"gemu/pkg/cartridge"
+	"gemu/pkg/interrupt"
+	"gemu/pkg/joypad"
+	"gemu/pkg/logger"
+	"gemu/pkg/ppu"
+	"gemu/pkg/serial"
+	"gemu/pkg/timer"
+	"strings"
+)
 
 /* https://gbdev.io/pandocs/Memory_Map.html
 
@@ -69,6 +80,9 @@ const (
 	IE                       // Interrupt Enable register (IE)
 )
 
+// BOOT is the register the boot ROM writes to, to unmap itself once it's done
+const BOOT = uint16(0xFF50)
+
 func (e MemRegion) String() string {
 	switch e {
 	case ROM0:
@@ -88,7 +102,7 @@ func (e MemRegion) String() string {
 	case OAM:
 		return "OAM"
 	case Unused:
-		return "Usused"
+		return "Unused"
 	case IO:
 		return "IO"
 	case HRAM:
@@ -100,6 +114,46 @@ func (e MemRegion) String() string {
 	}
 }
 
+// Bounds returns the first and last address of the region
+func (e MemRegion) Bounds() (start, end uint16) {
+	switch e {
+	case ROM0:
+		return 0x0000, 0x3FFF
+	case ROMX:
+		return 0x4000, 0x7FFF
+	case VRAM:
+		return 0x8000, 0x9FFF
+	case SRAM:
+		return 0xA000, 0xBFFF
+	case WRAM0:
+		return 0xC000, 0xCFFF
+	case WRAMX:
+		return 0xD000, 0xDFFF
+	case Echo:
+		return 0xE000, 0xFDFF
+	case OAM:
+		return 0xFE00, 0xFE9F
+	case Unused:
+		return 0xFEA0, 0xFEFF
+	case IO:
+		return 0xFF00, 0xFF7F
+	case HRAM:
+		return 0xFF80, 0xFFFE
+	default:
+		return 0xFFFF, 0xFFFF
+	}
+}
+
+// ParseRegion returns the MemRegion with the given name, ignoring case
+func ParseRegion(name string) (MemRegion, bool) {
+	for r := ROM0; r <= IE; r++ {
+		if strings.EqualFold(name, r.String()) {
+			return r, true
+		}
+	}
+	return 0, false
+}
+
 // MMU is the Memory Management Unit. While the GameBoy did not have an actual
 // MMU, it makes sense for our emulator. The GameBoy uses Memory Mapping to talk to
 // various subsystems. The MMU will be responsible for handling that mapping and will
@@ -110,6 +164,25 @@ type MMU struct {
 
 	// TODO: Have different mapped sections of memory defined here?
 	// HighRAM, OAM, ROM Banks, etc?
+
+	// The cartridge plugged in, which owns 0000-7FFF and A000-BFFF.
+	// Without one, those regions are plain memory like the rest.
+	cart *cartridge.Cartridge
+
+	// The boot ROM is mapped over the start of the cartridge until it's disabled through BOOT
+	bootROM []uint8
+
+	// Memory mapped hardware, which is advanced in lockstep with the CPU through Tick
+	joypad joypad.Joypad
+	timer  timer.Timer
+	serial serial.Serial
+	ppu    ppu.PPU
+	apu    apu.APU
+	dma    dma
+
+	// The last invalid access, if any. The GameBoy has no way of signaling a bad access,
+	// so it's recorded here and picked up by the CPU once the current instruction is done.
+	fault error
 }
 
 // Dump will write the contents of memeory to stdout
@@ -126,11 +199,91 @@ func (mmu *MMU) Init() {
 	for i := 0; i < len(mmu.memory); i++ {
 		mmu.memory[i] = 0x00
 	}
+	mmu.fault = nil
+	mmu.bootROM = nil
+
+	// Bring the memory mapped hardware online
+	mmu.joypad.Init(mmu.RequestInterrupt)
+	mmu.timer.Init(mmu.RequestInterrupt)
+	mmu.serial.Init(mmu.RequestInterrupt)
+	mmu.ppu.Init(mmu.RequestInterrupt, mmu.readVideo)
+	mmu.apu.Init()
+	mmu.dma = dma{}
+}
+
+// readVideo reads VRAM and OAM for the PPU, bypassing the cartridge and fault checks
+func (mmu *MMU) readVideo(addr uint16) uint8 {
+	return mmu.memory[addr]
+}
+
+// Tick advances all of the memory mapped hardware by the given number of T-cycles.
+// The CPU calls this as it accesses memory, so everything stays in lockstep with it.
+func (mmu *MMU) Tick(cycles int) {
+	mmu.timer.Tick(cycles)
+	mmu.serial.Tick(cycles)
+	mmu.ppu.Tick(cycles)
+	mmu.apu.Tick(cycles)
+	mmu.tickDMA(cycles)
+	if mmu.cart != nil {
+		mmu.cart.Tick(cycles)
+	}
+}
+
+// RequestInterrupt sets the interrupt's flag in IF
+func (mmu *MMU) RequestInterrupt(f interrupt.Flag) {
+	mmu.memory[interrupt.IF] |= uint8(f)
+}
+
+// LoadCartridge plugs a cartridge into the cartridge slot
+func (mmu *MMU) LoadCartridge(cart *cartridge.Cartridge) {
+	mmu.cart = cart
+}
+
+// Cartridge returns the cartridge plugged in, or nil if the slot is empty
+func (mmu *MMU) Cartridge() *cartridge.Cartridge {
+	return mmu.cart
+}
+
+// ROMBank returns the ROM bank mapped at 4000-7FFF, which is bank 1 when the slot is empty
+func (mmu *MMU) ROMBank() int {
+	if mmu.cart == nil {
+		return 1
+	}
+	return mmu.cart.ROMBank()
+}
 
+// Serial returns the serial port
+func (mmu *MMU) Serial() *serial.Serial {
+	return &mmu.serial
+}
+
+// APU returns the APU
+func (mmu *MMU) APU() *apu.APU {
+	return &mmu.apu
+}
+
+// Joypad returns the joypad
+func (mmu *MMU) Joypad() *joypad.Joypad {
+	return &mmu.joypad
+}
+
+// MapBootROM maps the boot ROM over the start of the cartridge ROM, until the boot ROM disables itself
+func (mmu *MMU) MapBootROM(rom []uint8) {
+	mmu.bootROM = rom
+}
+
+// BootROMMapped reports if the boot ROM is still mapped over 0000-00FF
+func (mmu *MMU) BootROMMapped() bool {
+	return mmu.bootROM != nil
+}
+
+// PPU returns the Pixel Processing Unit
+func (mmu *MMU) PPU() *ppu.PPU {
+	return &mmu.ppu
 }
 
 // MapAddr maps the given memory address to the correct MemRegion
-func (*MMU) mapAddr(addr uint16) MemRegion {
+func MapAddr(addr uint16) MemRegion {
 	//if addr >= 0x0000 && addr <= 0x3FFF {
 	// go static check - uint16 will always be larger than 0x0000
 	if addr <= 0x3FFF {
@@ -155,12 +308,10 @@ func (*MMU) mapAddr(addr uint16) MemRegion {
 		return IO
 	} else if addr >= 0xFF80 && addr <= 0xFFFE {
 		return HRAM
-	} else if addr == 0xFFFF {
-		return IE
 	}
 
-	err := fmt.Errorf("[MapAddr] Can't map %x to region", addr)
-	panic(err)
+	// The only address left is 0xFFFF
+	return IE
 }
 
 // TODO: Need to make sure read/write is respecting memory mapping rules & other restrictions
@@ -168,21 +319,85 @@ func (*MMU) mapAddr(addr uint16) MemRegion {
 // Write will write an 8-bit value to the given memory address
 func (mmu *MMU) Write(addr uint16, value uint8) {
 	// Do not write to prohibited locations of memory
-	if mmu.mapAddr(addr) != MemRegion(Echo) && mmu.mapAddr(addr) != MemRegion(Unused) {
+	region := MapAddr(addr)
+	if region == Echo || region == Unused {
+		mmu.fault = &AccessError{Addr: addr, Region: region, Write: true, Value: value}
+		return
+	}
+
+	switch {
+	case region == IO:
+		mmu.writeIO(addr, value)
+	case mmu.cart != nil && (region == ROM0 || region == ROMX || region == SRAM):
+		mmu.cart.Write(addr, value)
+	default:
 		mmu.memory[addr] = value
-		fmt.Printf("[MMU Write] Wrote 0x%x to %s[0x%x]\n", value, MemRegion(mmu.mapAddr(addr)), addr)
-	} else {
-		err := fmt.Errorf("[MMU Write] Can't write to protected memory region 0x%x (%s)", addr, MemRegion(mmu.mapAddr(addr)))
-		panic(err)
+	}
+	if logger.MMU.Enabled(logger.Trace) {
+		logger.MMU.Tracef("Wrote 0x%x to %s[0x%x]", value, region, addr)
 	}
 }
 
 // Read will read from the given memory address
 func (mmu *MMU) Read(addr uint16) uint8 {
-	if addr <= 0xFFFF {
-		return mmu.memory[addr]
-	} else {
-		err := fmt.Errorf("[MMU Read] Can't read outside of memory range 0x0000 - 0xFFFF")
-		panic(err)
+	switch {
+	case addr < 0x0100 && mmu.bootROM != nil:
+		return mmu.bootROM[addr]
+	case mmu.cart != nil && (addr < 0x8000 || (addr >= 0xA000 && addr <= 0xBFFF)):
+		return mmu.cart.Read(addr)
+	case addr >= 0xFF00 && addr <= 0xFF7F:
+		return mmu.readIO(addr)
+	}
+	return mmu.memory[addr]
+}
+
+// readIO reads an I/O register, from the hardware that owns it
+func (mmu *MMU) readIO(addr uint16) uint8 {
+	switch {
+	case addr == joypad.P1:
+		return mmu.joypad.Read()
+	case addr == serial.SB || addr == serial.SC:
+		return mmu.serial.Read(addr)
+	case addr >= timer.DIV && addr <= timer.TAC:
+		return mmu.timer.Read(addr)
+	case addr == interrupt.IF:
+		// Only the lower 5 bits are used, the rest read as 1
+		return mmu.memory[addr] | 0xE0
+	case addr == DMA:
+		return mmu.dma.source
+	case addr >= ppu.LCDC && addr <= ppu.WX:
+		return mmu.ppu.Read(addr)
 	}
+	return mmu.memory[addr]
+}
+
+// writeIO writes an I/O register, to the hardware that owns it
+func (mmu *MMU) writeIO(addr uint16, value uint8) {
+	switch {
+	case addr == joypad.P1:
+		mmu.joypad.Write(value)
+	case addr == serial.SB || addr == serial.SC:
+		mmu.serial.Write(addr, value)
+	case addr >= timer.DIV && addr <= timer.TAC:
+		mmu.timer.Write(addr, value)
+	case addr == DMA:
+		mmu.startDMA(value)
+	case addr == BOOT:
+		// Writing anything but 0 unmaps the boot ROM, for good
+		if value != 0 {
+			mmu.bootROM = nil
+		}
+		mmu.memory[addr] = value
+	case addr >= ppu.LCDC && addr <= ppu.WX:
+		mmu.ppu.Write(addr, value)
+	default:
+		mmu.memory[addr] = value
+	}
+}
+
+// Fault returns the last invalid memory access since Fault was last called, or nil
+func (mmu *MMU) Fault() error {
+	err := mmu.fault
+	mmu.fault = nil
+	return err
 }
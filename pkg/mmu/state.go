@@ -0,0 +1,116 @@
+/*
+		gemu - the gameboy emulator
+				<3 m0x
+	    __________________________
+	   |                          |
+	   | .----------------------. |
+	   | |  .----------------.  | |
+	   | |  |                |  | |
+	   | |))|                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  '----------------'  | |
+	   | |__GAME BOY____________/ |
+	   |          ________        |
+	   |    .    (Nintendo)       |
+	   |  _| |_   """"""""   .-.  |
+	   |-[_   _]-       .-. (   ) |
+	   |   |_|         (   ) '-'  |
+	   |    '           '-'   A   |
+	   |                 B        |
+	   |          ___   ___       |
+	   |         (___) (___)  ,., |
+	   |        select start ;:;: |
+	   |                    ,;:;' /
+	   |                   ,:;:'.'
+	   '-----------------------`
+*/
+package mmu
+
+import (
+	"errors"
+	"gemu/pkg/savestate"
+)
+
+// errNoCartridge is returned when loading a state made with a cartridge into an empty slot
+var errNoCartridge = errors.New("save state was made with a cartridge, but none is loaded")
+
+// dmaState is the OAM DMA transfer's part of the MMU's section
+type dmaState struct {
+	Active bool
+	Source uint8
+	Index  uint16
+	Cycles int32
+}
+
+// SaveState writes the memory, the cartridge and all of the memory mapped hardware
+func (mmu *MMU) SaveState(e *savestate.Encoder) {
+	e.Section("MMU ", mmu.memory, dmaState{mmu.dma.active, mmu.dma.source, mmu.dma.index, int32(mmu.dma.cycles)})
+	if mmu.bootROM != nil {
+		e.Section("BOOT", mmu.bootROM)
+	}
+	if mmu.cart != nil {
+		mmu.cart.SaveState(e)
+	}
+
+	mmu.joypad.SaveState(e)
+	mmu.timer.SaveState(e)
+	mmu.serial.SaveState(e)
+	mmu.ppu.SaveState(e)
+	mmu.apu.SaveState(e)
+}
+
+// Check returns an error if the state can't be loaded into this MMU, before anything is changed
+func (mmu *MMU) Check(d *savestate.Decoder) error {
+	if mmu.cart != nil {
+		return mmu.cart.Check(d)
+	}
+	if d.Has("CART") {
+		return errNoCartridge
+	}
+	return nil
+}
+
+// LoadState restores the memory, the cartridge and all of the memory mapped hardware
+func (mmu *MMU) LoadState(d *savestate.Decoder) error {
+	if err := mmu.Check(d); err != nil {
+		return err
+	}
+
+	var s dmaState
+	if err := d.Section("MMU ", &mmu.memory, &s); err != nil {
+		return err
+	}
+	mmu.dma = dma{active: s.Active, source: s.Source, index: s.Index, cycles: int(s.Cycles)}
+
+	mmu.bootROM = nil
+	if boot, ok := d.Bytes("BOOT"); ok {
+		mmu.bootROM = boot
+	}
+	mmu.fault = nil
+
+	if mmu.cart != nil {
+		if err := mmu.cart.LoadState(d); err != nil {
+			return err
+		}
+	}
+	// States from before the joypad was emulated don't have it
+	if d.Has("JOYP") {
+		if err := mmu.joypad.LoadState(d); err != nil {
+			return err
+		}
+	}
+	if err := mmu.timer.LoadState(d); err != nil {
+		return err
+	}
+	if err := mmu.serial.LoadState(d); err != nil {
+		return err
+	}
+	if err := mmu.ppu.LoadState(d); err != nil {
+		return err
+	}
+	return mmu.apu.LoadState(d)
+}
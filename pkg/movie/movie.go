@@ -0,0 +1,297 @@
+/*
+		gemu - the gameboy emulator
+				<3 m0x
+	    __________________________
+	   |                          |
+	   | .----------------------. |
+	   | |  .----------------.  | |
+	   | |  |                |  | |
+	   | |))|                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  '----------------'  | |
+	   | |__GAME BOY____________/ |
+	   |          ________        |
+	   |    .    (Nintendo)       |
+	   |  _| |_   """"""""   .-.  |
+	   |-[_   _]-       .-. (   ) |
+	   |   |_|         (   ) '-'  |
+	   |    '           '-'   A   |
+	   |                 B        |
+	   |          ___   ___       |
+	   |         (___) (___)  ,., |
+	   |        select start ;:;: |
+	   |                    ,;:;' /
+	   |                   ,:;:'.'
+	   '-----------------------`
+*/
+package movie
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gemu/pkg/boot"
+	"gemu/pkg/gb"
+	"gemu/pkg/joypad"
+	"gemu/pkg/logger"
+	"io"
+	"os"
+	"time"
+)
+
+/*	Movie format
+
+A movie is the joypad input for every frame from power on. The GameBoy is deterministic given
+its input, so playing the input back into the same ROM with the same settings reproduces the run
+exactly, down to the cycle. All numbers are little endian.
+
+Offset	Size	Description
+0		8		Magic, "GEMUMOVI"
+8		2		Version
+10		4		Length of the header
+14		...		Header, JSON, see Header
+...		4		Number of frames
+...		...		Input, one byte of joypad.Buttons per frame
+*/
+
+// Magic identifies a movie
+const Magic = "GEMUMOVI"
+
+// Version is the version of the format that's written
+const Version = 1
+
+// Model is the hardware movies are recorded on
+const Model = "DMG"
+
+// ErrNotMovie is returned when reading something that isn't a movie
+var ErrNotMovie = errors.New("not a gemu movie")
+
+// Header describes what a movie was recorded with, everything that has to match for it to play back the same
+type Header struct {
+	Title    string    `json:"title"`    // Title from the cartridge header, for people reading it
+	ROM      string    `json:"rom_sha1"` // SHA-1 of the ROM
+	Model    string    `json:"model"`
+	BootROM  string    `json:"boot_rom_sha1"` // SHA-1 of the boot ROM
+	Lenient  bool      `json:"lenient"`       // Were unused opcodes skipped, see gb.GameBoy.Lenient
+	RTCStart int64     `json:"rtc_start"`     // Seconds the cartridge's RTC started at
+	Recorded time.Time `json:"recorded"`
+}
+
+// Movie is a recorded run
+type Movie struct {
+	Header Header
+	Input  []joypad.Buttons
+}
+
+// header returns the header for movies of a GameBoy, which must have a ROM loaded
+func header(gemu *gb.GameBoy) (Header, error) {
+	cart := gemu.MMU().Cartridge()
+	if cart == nil {
+		return Header{}, errors.New("movies need a ROM to be loaded")
+	}
+
+	sum, bootSum := cart.SHA1(), sha1.Sum(boot.BootRom)
+	return Header{
+		Title:    cart.Header.Title,
+		ROM:      hex.EncodeToString(sum[:]),
+		Model:    Model,
+		BootROM:  hex.EncodeToString(bootSum[:]),
+		Lenient:  gemu.Lenient,
+		RTCStart: int64(gemu.RTCStart / time.Second),
+	}, nil
+}
+
+// Read reads a movie
+func Read(r io.Reader) (*Movie, error) {
+	br := bufio.NewReader(r)
+	magic := make([]byte, len(Magic))
+	if _, err := io.ReadFull(br, magic); err != nil || string(magic) != Magic {
+		return nil, ErrNotMovie
+	}
+
+	var version uint16
+	if err := binary.Read(br, binary.LittleEndian, &version); err != nil {
+		return nil, err
+	}
+	if version > Version {
+		return nil, fmt.Errorf("movie is version %d, this version of gemu reads up to %d", version, Version)
+	}
+
+	var length uint32
+	if err := binary.Read(br, binary.LittleEndian, &length); err != nil {
+		return nil, err
+	}
+	header := make([]byte, length)
+	if _, err := io.ReadFull(br, header); err != nil {
+		return nil, err
+	}
+	m := new(Movie)
+	if err := json.Unmarshal(header, &m.Header); err != nil {
+		return nil, fmt.Errorf("bad movie header - %w", err)
+	}
+
+	var frames uint32
+	if err := binary.Read(br, binary.LittleEndian, &frames); err != nil {
+		return nil, err
+	}
+	m.Input = make([]joypad.Buttons, frames)
+	if err := binary.Read(br, binary.LittleEndian, m.Input); err != nil {
+		return nil, fmt.Errorf("movie is truncated - %w", err)
+	}
+	return m, nil
+}
+
+// Load reads a movie from a file
+func Load(path string) (*Movie, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Read(f)
+}
+
+// Write writes the movie
+func (m *Movie) Write(w io.Writer) error {
+	header, err := json.Marshal(m.Header)
+	if err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(w)
+	for _, v := range []interface{}{[]byte(Magic), uint16(Version), uint32(len(header)), header, uint32(len(m.Input)), m.Input} {
+		if err := binary.Write(bw, binary.LittleEndian, v); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// Save writes the movie to a file
+func (m *Movie) Save(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := m.Write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Recorder records a GameBoy's input into a movie
+type Recorder struct {
+	Movie *Movie
+	Path  string // Where Close writes the movie
+}
+
+// Record starts recording a GameBoy's input, which must be at power on with its ROM loaded.
+// The movie is written to path by Close.
+//
+// Rewinding while recording takes the movie back with it, so the movie ends up as the run
+// that was kept. Loading a save state while recording breaks the movie, it can't replay that.
+func Record(gemu *gb.GameBoy, path string) (*Recorder, error) {
+	if gemu.FrameCount() != 0 {
+		return nil, errors.New("movies have to be recorded from power on")
+	}
+	h, err := header(gemu)
+	if err != nil {
+		return nil, err
+	}
+	h.Recorded = time.Now().UTC()
+
+	r := &Recorder{Movie: &Movie{Header: h}, Path: path}
+	next := gemu.OnInput
+	gemu.OnInput = func(frame uint64, buttons joypad.Buttons) joypad.Buttons {
+		if next != nil {
+			buttons = next(frame, buttons)
+		}
+
+		input := r.Movie.Input
+		if frame > uint64(len(input)) {
+			logger.GB.Warnf("Movie skipped from frame %d to %d, it won't play back the same", len(input), frame)
+			for uint64(len(input)) < frame {
+				input = append(input, 0)
+			}
+		}
+		r.Movie.Input = append(input[:frame], buttons)
+		return buttons
+	}
+	return r, nil
+}
+
+// Close stops recording and writes the movie
+func (r *Recorder) Close() error {
+	return r.Movie.Save(r.Path)
+}
+
+// Player plays a movie's input back into a GameBoy
+type Player struct {
+	Movie *Movie
+	done  bool
+}
+
+// Play starts playing a movie into a GameBoy, which must be at power on with the movie's ROM loaded.
+// The movie's settings are applied to it. Once the movie ends, the player's input goes through again.
+func Play(gemu *gb.GameBoy, m *Movie) (*Player, error) {
+	if gemu.FrameCount() != 0 {
+		return nil, errors.New("movies have to be played from power on")
+	}
+	h, err := header(gemu)
+	if err != nil {
+		return nil, err
+	}
+	if h.ROM != m.Header.ROM {
+		return nil, fmt.Errorf("movie was recorded with another ROM, %q (SHA-1 %s)", m.Header.Title, m.Header.ROM)
+	}
+	if h.Model != m.Header.Model {
+		return nil, fmt.Errorf("movie was recorded on a %s, not a %s", m.Header.Model, h.Model)
+	}
+	if h.BootROM != m.Header.BootROM {
+		logger.GB.Warnf("Movie was recorded with another boot ROM, it may not play back the same")
+	}
+
+	// Play with the settings it was recorded with
+	gemu.Lenient = m.Header.Lenient
+	gemu.CPU().Lenient = m.Header.Lenient
+	gemu.RTCStart = time.Duration(m.Header.RTCStart) * time.Second
+	if rtc := gemu.MMU().Cartridge().RTC(); rtc != nil {
+		rtc.Set(gemu.RTCStart)
+	}
+
+	p := &Player{Movie: m}
+	next := gemu.OnInput
+	gemu.OnInput = func(frame uint64, buttons joypad.Buttons) joypad.Buttons {
+		if frame < uint64(len(m.Input)) {
+			buttons = m.Input[frame]
+		} else if !p.done {
+			p.done = true
+			logger.GB.Infof("Movie finished after %d frames", len(m.Input))
+		}
+		if next != nil {
+			buttons = next(frame, buttons)
+		}
+		return buttons
+	}
+	return p, nil
+}
+
+// Len returns the number of frames in the movie
+func (p *Player) Len() int {
+	return len(p.Movie.Input)
+}
+
+// Done reports if the movie has finished playing. It's only safe to call from the goroutine
+// running the GameBoy.
+func (p *Player) Done() bool {
+	return p.done
+}
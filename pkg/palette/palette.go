@@ -0,0 +1,193 @@
+/*
+		gemu - the gameboy emulator
+				<3 m0x
+	    __________________________
+	   |                          |
+	   | .----------------------. |
+	   | |  .----------------.  | |
+	   | |  |                |  | |
+	   | |))|                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  '----------------'  | |
+	   | |__GAME BOY____________/ |
+	   |          ________        |
+	   |    .    (Nintendo)       |
+	   |  _| |_   """"""""   .-.  |
+	   |-[_   _]-       .-. (   ) |
+	   |   |_|         (   ) '-'  |
+	   |    '           '-'   A   |
+	   |                 B        |
+	   |          ___   ___       |
+	   |         (___) (___)  ,., |
+	   |        select start ;:;: |
+	   |                    ,;:;' /
+	   |                   ,:;:'.'
+	   '-----------------------`
+*/
+package palette
+
+import (
+	"bufio"
+	"fmt"
+	"gemu/pkg/ppu"
+	"image/color"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+/*	Palette files
+
+A palette file is plain text, with a line for each palette register it colours. Each line has
+the register followed by its 4 colours as hex RGB, from the lightest shade to the darkest.
+"all" colours every register, and registers that aren't given use the BG colours.
+
+	# Everything green, with red objects
+	name	Pea soup
+	all		9BBC0F 8BAC0F 306230 0F380F
+	obp0	FFC0C0 FF6060 A00000 400000
+
+Blank lines and lines starting with # are ignored.
+*/
+
+// Palette is a named way of colouring the screen
+type Palette struct {
+	Name string
+	ppu.Palettes
+}
+
+// rgb returns a palette from 4 colours as 0xRRGGBB
+func rgb(c0, c1, c2, c3 uint32) ppu.Palette {
+	var p ppu.Palette
+	for i, c := range []uint32{c0, c1, c2, c3} {
+		p[i] = color.RGBA{uint8(c >> 16), uint8(c >> 8), uint8(c), 0xFF}
+	}
+	return p
+}
+
+// Builtin are the palettes gemu comes with, the first is the default
+var Builtin = []Palette{
+	{"grey", ppu.Uniform(ppu.GreyPalette)},
+	{"dmg", ppu.Uniform(rgb(0x9BBC0F, 0x8BAC0F, 0x306230, 0x0F380F))},    // The original pea green
+	{"pocket", ppu.Uniform(rgb(0xC4CFA1, 0x8B956D, 0x4D533C, 0x1F1F1F))}, // The Game Boy Pocket's greys
+	{"light", ppu.Uniform(rgb(0x00B581, 0x009A71, 0x00694A, 0x004F3B))},  // The Game Boy Light's backlight
+}
+
+// Find returns the builtin palette with the given name, ignoring case
+func Find(name string) (Palette, bool) {
+	for _, p := range Builtin {
+		if strings.EqualFold(p.Name, name) {
+			return p, true
+		}
+	}
+	return Palette{}, false
+}
+
+// Get returns the builtin palette with the given name, or else loads it from a palette file
+func Get(nameOrPath string) (Palette, error) {
+	if p, ok := Find(nameOrPath); ok {
+		return p, nil
+	}
+	if _, err := os.Stat(nameOrPath); err != nil {
+		names := make([]string, len(Builtin))
+		for i, p := range Builtin {
+			names[i] = p.Name
+		}
+		return Palette{}, fmt.Errorf("%q isn't a palette file or one of %s", nameOrPath, strings.Join(names, ", "))
+	}
+	return Load(nameOrPath)
+}
+
+// Load loads a palette file, the palette is named after the file unless it has a name
+func Load(path string) (Palette, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Palette{}, err
+	}
+	defer f.Close()
+
+	p, err := Parse(f)
+	if err != nil {
+		return Palette{}, fmt.Errorf("%s: %w", path, err)
+	}
+	if p.Name == "" {
+		p.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	return p, nil
+}
+
+// Parse parses a palette file
+func Parse(r io.Reader) (Palette, error) {
+	var p Palette
+	var bg, obp0, obp1 bool
+
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		key := strings.ToLower(fields[0])
+		if key == "name" {
+			p.Name = strings.TrimSpace(line[len(fields[0]):])
+			continue
+		}
+
+		colors, err := parseColors(fields[1:])
+		if err != nil {
+			return Palette{}, fmt.Errorf("line %d: %w", n, err)
+		}
+		switch key {
+		case "all":
+			p.Palettes = ppu.Uniform(colors)
+			bg, obp0, obp1 = true, true, true
+		case "bg":
+			p.BG, bg = colors, true
+		case "obp0":
+			p.OBP0, obp0 = colors, true
+		case "obp1":
+			p.OBP1, obp1 = colors, true
+		default:
+			return Palette{}, fmt.Errorf("line %d: unknown palette register %q, expected all, bg, obp0 or obp1", n, fields[0])
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return Palette{}, err
+	}
+
+	if !bg {
+		return Palette{}, fmt.Errorf("no bg or all colours")
+	}
+	if !obp0 {
+		p.OBP0 = p.BG
+	}
+	if !obp1 {
+		p.OBP1 = p.BG
+	}
+	return p, nil
+}
+
+// parseColors parses the 4 colours of a palette as hex RGB, e.g. "9BBC0F" or "#9BBC0F"
+func parseColors(fields []string) (ppu.Palette, error) {
+	var p ppu.Palette
+	if len(fields) != len(p) {
+		return p, fmt.Errorf("expected %d colours, got %d", len(p), len(fields))
+	}
+	for i, f := range fields {
+		hex := strings.TrimPrefix(f, "#")
+		c, err := strconv.ParseUint(hex, 16, 32)
+		if err != nil || len(hex) != 6 {
+			return p, fmt.Errorf("bad colour %q, expected RRGGBB", f)
+		}
+		p[i] = color.RGBA{uint8(c >> 16), uint8(c >> 8), uint8(c), 0xFF}
+	}
+	return p, nil
+}
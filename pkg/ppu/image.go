@@ -0,0 +1,83 @@
+/*
+		gemu - the gameboy emulator
+				<3 m0x
+	    __________________________
+	   |                          |
+	   | .----------------------. |
+	   | |  .----------------.  | |
+	   | |  |                |  | |
+	   | |))|                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  '----------------'  | |
+	   | |__GAME BOY____________/ |
+	   |          ________        |
+	   |    .    (Nintendo)       |
+	   |  _| |_   """"""""   .-.  |
+	   |-[_   _]-       .-. (   ) |
+	   |   |_|         (   ) '-'  |
+	   |    '           '-'   A   |
+	   |                 B        |
+	   |          ___   ___       |
+	   |         (___) (___)  ,., |
+	   |        select start ;:;: |
+	   |                    ,;:;' /
+	   |                   ,:;:'.'
+	   '-----------------------`
+*/
+package ppu
+
+import (
+	"image"
+	"image/color"
+)
+
+// Palette maps the 4 shades to colours, from white to black
+type Palette [4]color.RGBA
+
+// GreyPalette is plain greys, the fixed palette reference screenshots like dmg-acid2's are taken with
+var GreyPalette = Palette{
+	{0xFF, 0xFF, 0xFF, 0xFF},
+	{0xAA, 0xAA, 0xAA, 0xFF},
+	{0x55, 0x55, 0x55, 0xFF},
+	{0x00, 0x00, 0x00, 0xFF},
+}
+
+// Palettes colours the shades of each palette register separately
+type Palettes struct {
+	BG, OBP0, OBP1 Palette
+}
+
+// Uniform returns Palettes colouring everything with the same palette
+func Uniform(palette Palette) Palettes {
+	return Palettes{palette, palette, palette}
+}
+
+// Color returns the colour of a Frame pixel
+func (p *Palettes) Color(pixel uint8) color.RGBA {
+	switch pixel &^ 0x03 {
+	case LayerOBP0:
+		return p.OBP0[pixel&0x03]
+	case LayerOBP1:
+		return p.OBP1[pixel&0x03]
+	}
+	return p.BG[pixel&0x03]
+}
+
+// Image converts a frame to an image, colouring its shades with a palette
+func (f *Frame) Image(palette Palette) *image.RGBA {
+	return f.ImagePalettes(Uniform(palette))
+}
+
+// ImagePalettes converts a frame to an image, colouring the shades of each palette register separately
+func (f *Frame) ImagePalettes(p Palettes) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, ScreenWidth, ScreenHeight))
+	for i, s := range f {
+		c := p.Color(s)
+		copy(img.Pix[i*4:], []uint8{c.R, c.G, c.B, c.A})
+	}
+	return img
+}
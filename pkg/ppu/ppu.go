@@ -29,3 +29,243 @@
 	   '-----------------------`
 */
 package ppu
+
+import "gemu/pkg/interrupt"
+
+/*	https://gbdev.io/pandocs/Rendering.html
+	https://gbdev.io/pandocs/STAT.html
+
+A frame is 154 scanlines of 456 dots (T-cycles) each, 70224 T-cycles in total.
+Lines 0-143 are visible and go through modes 2, 3 and 0, lines 144-153 are VBlank (mode 1).
+
+Mode	Name			Duration
+2		OAM scan		80 dots
+3		Drawing			172-289 dots (fixed at 172 for now)
+0		HBlank			the rest of the 456 dots
+1		VBlank			10 whole lines
+
+Addr	Name	Description
+FF40	LCDC	LCD control
+FF41	STAT	LCD status - bits 3-6 select STAT interrupt sources, bit 2 LY=LYC, bits 0-1 mode
+FF42	SCY		Background viewport Y
+FF43	SCX		Background viewport X
+FF44	LY		Current scanline (read only)
+FF45	LYC		LY compare
+FF47	BGP		Background palette
+FF48	OBP0	Object palette 0
+FF49	OBP1	Object palette 1
+FF4A	WY		Window Y
+FF4B	WX		Window X + 7
+*/
+
+// Register addresses
+const (
+	LCDC = uint16(0xFF40)
+	STAT = uint16(0xFF41)
+	SCY  = uint16(0xFF42)
+	SCX  = uint16(0xFF43)
+	LY   = uint16(0xFF44)
+	LYC  = uint16(0xFF45)
+	BGP  = uint16(0xFF47)
+	OBP0 = uint16(0xFF48)
+	OBP1 = uint16(0xFF49)
+	WY   = uint16(0xFF4A)
+	WX   = uint16(0xFF4B)
+)
+
+// Screen size, in pixels
+const (
+	ScreenWidth  = 160
+	ScreenHeight = 144
+)
+
+// Screen timing
+const (
+	DotsPerLine    = 456
+	Lines          = 154
+	VisibleLines   = 144
+	CyclesPerFrame = DotsPerLine * Lines // 70224 T-cycles
+)
+
+// Mode is the PPU's current mode, as shown in STAT
+type Mode uint8
+
+const (
+	ModeHBlank  = Mode(iota) // Mode 0
+	ModeVBlank               // Mode 1
+	ModeOAMScan              // Mode 2
+	ModeDrawing              // Mode 3
+)
+
+// Memory reads VRAM and OAM for the PPU, which don't belong to it
+type Memory func(addr uint16) uint8
+
+// PPU is the Pixel Processing Unit
+type PPU struct {
+	lcdc, stat, scy, scx, ly, lyc, bgp, obp0, obp1, wy, wx uint8
+
+	// Current dot within the scanline
+	dot int
+
+	// The STAT interrupt line, which only requests an interrupt on a rising edge
+	statLine bool
+
+	// The frame being drawn, and the last one finished
+	back, front Frame
+	frames      uint64
+
+	// The window has its own line counter, which only advances on lines it's drawn on
+	windowLine int
+
+	request interrupt.Request
+	mem     Memory
+}
+
+// Init resets the PPU, interrupts are raised through request and VRAM and OAM are read through mem
+func (p *PPU) Init(request interrupt.Request, mem Memory) {
+	*p = PPU{request: request, mem: mem}
+}
+
+// Tick advances the PPU by the given number of T-cycles
+func (p *PPU) Tick(cycles int) {
+	// Nothing happens while the LCD is off
+	if p.lcdc&0x80 == 0 {
+		return
+	}
+
+	for i := 0; i < cycles; i++ {
+		p.dot++
+
+		// The whole line is drawn as the PPU goes into HBlank
+		if p.dot == 80+172 && p.ly < VisibleLines {
+			p.renderLine()
+		}
+
+		if p.dot == DotsPerLine {
+			p.dot = 0
+			p.ly++
+			if p.ly == Lines {
+				p.ly = 0
+				p.windowLine = 0
+			}
+			if p.ly == VisibleLines {
+				p.front = p.back
+				p.frames++
+				p.request(interrupt.VBlank)
+			}
+		}
+
+		p.updateStat()
+	}
+}
+
+// Mode returns the PPU's current mode
+func (p *PPU) Mode() Mode {
+	return Mode(p.stat & 0x03)
+}
+
+// mode works out the mode for the current line and dot
+func (p *PPU) mode() Mode {
+	switch {
+	case p.lcdc&0x80 == 0:
+		return ModeHBlank
+	case p.ly >= VisibleLines:
+		return ModeVBlank
+	case p.dot < 80:
+		return ModeOAMScan
+	case p.dot < 80+172:
+		return ModeDrawing
+	default:
+		return ModeHBlank
+	}
+}
+
+// updateStat refreshes the mode and coincidence bits in STAT and requests
+// a STAT interrupt when the interrupt line goes high
+func (p *PPU) updateStat() {
+	mode := p.mode()
+	p.stat = p.stat&^0x07 | uint8(mode)
+	if p.ly == p.lyc {
+		p.stat |= 0x04
+	}
+
+	line := (p.stat&0x40 != 0 && p.stat&0x04 != 0) ||
+		(p.stat&0x20 != 0 && mode == ModeOAMScan) ||
+		(p.stat&0x10 != 0 && mode == ModeVBlank) ||
+		(p.stat&0x08 != 0 && mode == ModeHBlank)
+
+	if line && !p.statLine {
+		p.request(interrupt.LCDStat)
+	}
+	p.statLine = line
+}
+
+// Read returns the value of a PPU register
+func (p *PPU) Read(addr uint16) uint8 {
+	switch addr {
+	case LCDC:
+		return p.lcdc
+	case STAT:
+		// Bit 7 is unused and reads as 1
+		return p.stat | 0x80
+	case SCY:
+		return p.scy
+	case SCX:
+		return p.scx
+	case LY:
+		return p.ly
+	case LYC:
+		return p.lyc
+	case BGP:
+		return p.bgp
+	case OBP0:
+		return p.obp0
+	case OBP1:
+		return p.obp1
+	case WY:
+		return p.wy
+	case WX:
+		return p.wx
+	}
+	return 0xFF
+}
+
+// Write sets the value of a PPU register
+func (p *PPU) Write(addr uint16, value uint8) {
+	switch addr {
+	case LCDC:
+		// Turning the LCD off resets it back to the start of the frame, and blanks the screen
+		if value&0x80 == 0 && p.lcdc&0x80 != 0 {
+			p.ly = 0
+			p.dot = 0
+			p.windowLine = 0
+			p.front = Frame{}
+			p.frames++
+		}
+		p.lcdc = value
+	case STAT:
+		// Only the interrupt select bits are writable
+		p.stat = p.stat&0x07 | value&0x78
+	case SCY:
+		p.scy = value
+	case SCX:
+		p.scx = value
+	case LY:
+		// Read only
+		return
+	case LYC:
+		p.lyc = value
+	case BGP:
+		p.bgp = value
+	case OBP0:
+		p.obp0 = value
+	case OBP1:
+		p.obp1 = value
+	case WY:
+		p.wy = value
+	case WX:
+		p.wx = value
+	}
+
+	p.updateStat()
+}
@@ -0,0 +1,229 @@
+/*
+		gemu - the gameboy emulator
+				<3 m0x
+	    __________________________
+	   |                          |
+	   | .----------------------. |
+	   | |  .----------------.  | |
+	   | |  |                |  | |
+	   | |))|                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  '----------------'  | |
+	   | |__GAME BOY____________/ |
+	   |          ________        |
+	   |    .    (Nintendo)       |
+	   |  _| |_   """"""""   .-.  |
+	   |-[_   _]-       .-. (   ) |
+	   |   |_|         (   ) '-'  |
+	   |    '           '-'   A   |
+	   |                 B        |
+	   |          ___   ___       |
+	   |         (___) (___)  ,., |
+	   |        select start ;:;: |
+	   |                    ,;:;' /
+	   |                   ,:;:'.'
+	   '-----------------------`
+*/
+package ppu
+
+/*	https://gbdev.io/pandocs/Tile_Data.html
+	https://gbdev.io/pandocs/OAM.html
+
+Lines are drawn in one go rather than pixel by pixel through the FIFO, which is enough for
+everything that doesn't change registers mid-line.
+
+Tiles are 8x8 pixels at 2 bits per pixel, 16 bytes each. LCDC picks where they are:
+
+Bit	Name					0			1
+7	LCD enable				off			on
+6	Window tile map			9800-9BFF	9C00-9FFF
+5	Window enable			off			on
+4	BG & window tile data	8800-97FF	8000-8FFF
+3	BG tile map				9800-9BFF	9C00-9FFF
+2	OBJ size				8x8			8x16
+1	OBJ enable				off			on
+0	BG & window enable		off			on
+
+Each of the 40 objects in OAM (FE00-FE9F) is 4 bytes - Y+16, X+8, tile and attributes.
+Only the first 10 objects on a line are drawn.
+*/
+
+// Frame is a finished frame, row by row. Each pixel has its shade from 0 (white) to 3 (black) in bits 0-1,
+// and which palette register the shade came from in bits 2-3, so each can be coloured differently.
+type Frame [ScreenWidth * ScreenHeight]uint8
+
+// Which palette register a pixel's shade came from, in bits 2-3 of a Frame's pixels
+const (
+	LayerBG   = 0 << 2 // BGP, the background and window
+	LayerOBP0 = 1 << 2 // OBP0, objects
+	LayerOBP1 = 2 << 2 // OBP1, objects
+)
+
+// Frame returns the last finished frame. It's overwritten by the next one, so copy it to keep it.
+func (p *PPU) Frame() *Frame {
+	return &p.front
+}
+
+// Frames returns the number of frames finished since power on, which changes whenever Frame does
+func (p *PPU) Frames() uint64 {
+	return p.frames
+}
+
+// objectsPerLine is how many objects the PPU can draw on a single line
+const objectsPerLine = 10
+
+// renderLine draws the current line into the back buffer
+func (p *PPU) renderLine() {
+	// Colour numbers before the palette is applied, objects need them to decide their priority
+	var colors [ScreenWidth]uint8
+
+	if p.lcdc&0x01 != 0 {
+		p.renderBackground(&colors)
+		p.renderWindow(&colors)
+	}
+
+	line := p.back[int(p.ly)*ScreenWidth:][:ScreenWidth]
+	for x, c := range colors {
+		line[x] = shade(p.bgp, c) | LayerBG
+	}
+
+	if p.lcdc&0x02 != 0 {
+		p.renderObjects(&colors, line)
+	}
+}
+
+// renderBackground draws the background, which scrolls and wraps around its 256x256 map
+func (p *PPU) renderBackground(colors *[ScreenWidth]uint8) {
+	tileMap := uint16(0x9800)
+	if p.lcdc&0x08 != 0 {
+		tileMap = 0x9C00
+	}
+
+	y := p.ly + p.scy
+	for x := 0; x < ScreenWidth; x++ {
+		colors[x] = p.tileMapPixel(tileMap, uint8(x)+p.scx, y)
+	}
+}
+
+// renderWindow draws the window over the background, from WX-7, WY down to the bottom right corner
+func (p *PPU) renderWindow(colors *[ScreenWidth]uint8) {
+	if p.lcdc&0x20 == 0 || p.ly < p.wy || p.wx > 166 {
+		return
+	}
+
+	tileMap := uint16(0x9800)
+	if p.lcdc&0x40 != 0 {
+		tileMap = 0x9C00
+	}
+
+	for x := int(p.wx) - 7; x < ScreenWidth; x++ {
+		if x >= 0 {
+			colors[x] = p.tileMapPixel(tileMap, uint8(x-int(p.wx)+7), uint8(p.windowLine))
+		}
+	}
+	p.windowLine++
+}
+
+// tileMapPixel returns the colour number of a pixel in a background or window tile map
+func (p *PPU) tileMapPixel(tileMap uint16, x, y uint8) uint8 {
+	tile := p.mem(tileMap + uint16(y/8)*32 + uint16(x/8))
+
+	// 8000 addressing uses unsigned tile numbers, 8800 addressing signed ones from 9000
+	var addr uint16
+	if p.lcdc&0x10 != 0 {
+		addr = 0x8000 + uint16(tile)*16
+	} else {
+		addr = uint16(0x9000 + int(int8(tile))*16)
+	}
+	return p.tilePixel(addr, x%8, y%8)
+}
+
+// tilePixel returns the colour number of a pixel within a tile
+func (p *PPU) tilePixel(addr uint16, x, y uint8) uint8 {
+	lo := p.mem(addr + uint16(y)*2)
+	hi := p.mem(addr + uint16(y)*2 + 1)
+	bit := 7 - x
+	return (hi>>bit&1)<<1 | lo>>bit&1
+}
+
+// object is an entry in OAM
+type object struct {
+	y, x, tile, attr uint8
+	index            int
+}
+
+// renderObjects draws the objects on the current line over the background
+func (p *PPU) renderObjects(colors *[ScreenWidth]uint8, line []uint8) {
+	height := uint8(8)
+	if p.lcdc&0x04 != 0 {
+		height = 16
+	}
+
+	// Pick the first 10 objects in OAM that are on this line
+	var objects []object
+	for i := 0; i < 40 && len(objects) < objectsPerLine; i++ {
+		addr := 0xFE00 + uint16(i)*4
+		o := object{y: p.mem(addr), x: p.mem(addr + 1), tile: p.mem(addr + 2), attr: p.mem(addr + 3), index: i}
+		if top := int(o.y) - 16; int(p.ly) >= top && int(p.ly) < top+int(height) {
+			objects = append(objects, o)
+		}
+	}
+
+	// Objects further left win, then earlier ones in OAM. Drawing the winners last puts them on top.
+	sortObjects(objects)
+
+	for i := len(objects) - 1; i >= 0; i-- {
+		o := objects[i]
+
+		row := p.ly - (o.y - 16)
+		if o.attr&0x40 != 0 {
+			row = height - 1 - row
+		}
+		tile := o.tile
+		if height == 16 {
+			tile &^= 0x01
+		}
+
+		palette, layer := p.obp0, uint8(LayerOBP0)
+		if o.attr&0x10 != 0 {
+			palette, layer = p.obp1, LayerOBP1
+		}
+
+		for px := uint8(0); px < 8; px++ {
+			x := int(o.x) - 8 + int(px)
+			if x < 0 || x >= ScreenWidth {
+				continue
+			}
+
+			col := px
+			if o.attr&0x20 != 0 {
+				col = 7 - px
+			}
+			c := p.tilePixel(0x8000+uint16(tile)*16, col, row)
+
+			// Colour 0 is transparent, and with the priority bit set the background's colours 1-3 stay on top
+			if c == 0 || (o.attr&0x80 != 0 && colors[x] != 0) {
+				continue
+			}
+			line[x] = shade(palette, c) | layer
+		}
+	}
+}
+
+// sortObjects sorts objects by drawing priority, highest first
+func sortObjects(objects []object) {
+	for i := 1; i < len(objects); i++ {
+		for j := i; j > 0 && objects[j].x < objects[j-1].x; j-- {
+			objects[j], objects[j-1] = objects[j-1], objects[j]
+		}
+	}
+}
+
+// shade maps a colour number to a shade through a palette register
+func shade(palette, color uint8) uint8 {
+	return palette >> (color * 2) & 0x03
+}
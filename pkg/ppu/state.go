@@ -0,0 +1,76 @@
+/*
+		gemu - the gameboy emulator
+				<3 m0x
+	    __________________________
+	   |                          |
+	   | .----------------------. |
+	   | |  .----------------.  | |
+	   | |  |                |  | |
+	   | |))|                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  '----------------'  | |
+	   | |__GAME BOY____________/ |
+	   |          ________        |
+	   |    .    (Nintendo)       |
+	   |  _| |_   """"""""   .-.  |
+	   |-[_   _]-       .-. (   ) |
+	   |   |_|         (   ) '-'  |
+	   |    '           '-'   A   |
+	   |                 B        |
+	   |          ___   ___       |
+	   |         (___) (___)  ,., |
+	   |        select start ;:;: |
+	   |                    ,;:;' /
+	   |                   ,:;:'.'
+	   '-----------------------`
+*/
+package ppu
+
+import "gemu/pkg/savestate"
+
+// state is the PPU's section of a save state
+type state struct {
+	LCDC, STAT, SCY, SCX, LY, LYC, BGP, OBP0, OBP1, WY, WX uint8
+
+	Dot        int32
+	StatLine   bool
+	WindowLine int32
+	Frames     uint64
+	Back       Frame
+	Front      Frame
+}
+
+// SaveState writes the PPU's state, including the frame being drawn and the last one finished
+func (p *PPU) SaveState(e *savestate.Encoder) {
+	e.Section("PPU ", state{
+		LCDC: p.lcdc, STAT: p.stat, SCY: p.scy, SCX: p.scx, LY: p.ly, LYC: p.lyc,
+		BGP: p.bgp, OBP0: p.obp0, OBP1: p.obp1, WY: p.wy, WX: p.wx,
+		Dot:        int32(p.dot),
+		StatLine:   p.statLine,
+		WindowLine: int32(p.windowLine),
+		Frames:     p.frames,
+		Back:       p.back,
+		Front:      p.front,
+	})
+}
+
+// LoadState restores the PPU's state
+func (p *PPU) LoadState(d *savestate.Decoder) error {
+	var s state
+	if err := d.Section("PPU ", &s); err != nil {
+		return err
+	}
+
+	p.lcdc, p.stat, p.scy, p.scx, p.ly, p.lyc = s.LCDC, s.STAT, s.SCY, s.SCX, s.LY, s.LYC
+	p.bgp, p.obp0, p.obp1, p.wy, p.wx = s.BGP, s.OBP0, s.OBP1, s.WY, s.WX
+	p.dot = int(s.Dot)
+	p.statLine = s.StatLine
+	p.windowLine = int(s.WindowLine)
+	p.frames = s.Frames
+	p.back, p.front = s.Back, s.Front
+	return nil
+}
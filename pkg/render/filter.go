@@ -0,0 +1,187 @@
+/*
+		gemu - the gameboy emulator
+				<3 m0x
+	    __________________________
+	   |                          |
+	   | .----------------------. |
+	   | |  .----------------.  | |
+	   | |  |                |  | |
+	   | |))|                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  '----------------'  | |
+	   | |__GAME BOY____________/ |
+	   |          ________        |
+	   |    .    (Nintendo)       |
+	   |  _| |_   """"""""   .-.  |
+	   |-[_   _]-       .-. (   ) |
+	   |   |_|         (   ) '-'  |
+	   |    '           '-'   A   |
+	   |                 B        |
+	   |          ___   ___       |
+	   |         (___) (___)  ,., |
+	   |        select start ;:;: |
+	   |                    ,;:;' /
+	   |                   ,:;:'.'
+	   '-----------------------`
+*/
+package render
+
+import "gemu/pkg/ppu"
+
+/*	Display filters
+
+The filters run on the CPU over the ABGR8888 pixels, before they're uploaded to the texture, so
+they look the same whatever the renderer is, including the software renderer used on WSL.
+
+Ghosting blends each frame with the ones before it, like the DMG's slow LCD did. Games that flicker
+objects on and off every other frame for transparency rely on it. Persistence is how much of the
+last frame is left, from 0 (off) to just under 1.
+
+The pixel grid and LCD mask need more than one texel per GameBoy pixel, so with either of them on,
+each pixel becomes a 3x3 block. The grid darkens the right and bottom edge of each block, the
+mask tints its 3 columns red, green and blue like an LCD's subpixels.
+*/
+
+// filterScale is how many texels wide and high each pixel is with the grid or LCD mask on
+const filterScale = 3
+
+// MaxPersistence is the most ghosting there can be, any more and the screen would never change
+const MaxPersistence = 0.95
+
+// filters post-processes frames, see the package comment above
+type filters struct {
+	persistence float64
+	grid, mask  bool
+
+	// The blended frame, and if it holds a frame yet
+	ghost    []byte
+	hasGhost bool
+
+	// The filtered pixels, and how much of each channel every texel of a 3x3 block keeps out of 256
+	out     []byte
+	weights [filterScale][filterScale][3]uint16
+}
+
+func newFilters(persistence float64, grid, mask bool) *filters {
+	f := &filters{grid: grid, mask: mask, ghost: make([]byte, ppu.ScreenWidth*ppu.ScreenHeight*4)}
+	f.setPersistence(persistence)
+	f.setWeights()
+	return f
+}
+
+// scale returns how many texels wide and high each pixel is
+func (f *filters) scale() int {
+	if f.grid || f.mask {
+		return filterScale
+	}
+	return 1
+}
+
+// setPersistence sets how much of the last frame is left in the next, 0 turns ghosting off
+func (f *filters) setPersistence(persistence float64) {
+	if persistence < 0 {
+		persistence = 0
+	} else if persistence > MaxPersistence {
+		persistence = MaxPersistence
+	}
+	f.persistence = persistence
+}
+
+// toggleGrid switches the pixel grid on or off
+func (f *filters) toggleGrid() {
+	f.grid = !f.grid
+	f.setWeights()
+}
+
+// toggleMask switches the LCD mask on or off
+func (f *filters) toggleMask() {
+	f.mask = !f.mask
+	f.setWeights()
+}
+
+// setWeights works out the weights of each texel's channels, for the grid and mask in use
+func (f *filters) setWeights() {
+	for y := 0; y < filterScale; y++ {
+		for x := 0; x < filterScale; x++ {
+			for c := 0; c < 3; c++ {
+				w := uint16(256)
+				if f.mask && c != x {
+					// Each column is mostly its own subpixel's colour
+					w = w * 5 / 8
+				}
+				if f.grid && (x == filterScale-1 || y == filterScale-1) {
+					w = w * 3 / 4
+				}
+				f.weights[y][x][c] = w
+			}
+		}
+	}
+}
+
+// reset forgets the frames ghosting has blended, so the next frame shows as it is
+func (f *filters) reset() {
+	f.hasGhost = false
+}
+
+// apply filters a frame's pixels, returning the pixels to show and how many texels wide they are.
+// newFrame is false when the same frame is being filtered again, so it isn't blended with itself.
+func (f *filters) apply(pixels []byte, newFrame bool) ([]byte, int) {
+	src := pixels
+	if f.persistence > 0 {
+		if newFrame || !f.hasGhost {
+			f.blend(pixels)
+		}
+		src = f.ghost
+	} else {
+		f.hasGhost = false
+	}
+
+	if f.scale() == 1 {
+		return src, ppu.ScreenWidth
+	}
+	return f.expand(src), ppu.ScreenWidth * filterScale
+}
+
+// blend blends pixels into the ghost of the frames before it
+func (f *filters) blend(pixels []byte) {
+	if !f.hasGhost {
+		copy(f.ghost, pixels)
+		f.hasGhost = true
+		return
+	}
+
+	old := uint16(f.persistence * 256)
+	for i, p := range pixels {
+		f.ghost[i] = uint8((uint16(f.ghost[i])*old + uint16(p)*(256-old)) >> 8)
+	}
+}
+
+// expand turns each pixel into a 3x3 block, with the grid and mask applied
+func (f *filters) expand(src []byte) []byte {
+	const width = ppu.ScreenWidth * filterScale
+	if len(f.out) == 0 {
+		f.out = make([]byte, width*ppu.ScreenHeight*filterScale*4)
+	}
+
+	for y := 0; y < ppu.ScreenHeight; y++ {
+		for x := 0; x < ppu.ScreenWidth; x++ {
+			p := src[(y*ppu.ScreenWidth+x)*4:]
+			for dy := 0; dy < filterScale; dy++ {
+				row := f.out[((y*filterScale+dy)*width+x*filterScale)*4:]
+				for dx := 0; dx < filterScale; dx++ {
+					w := &f.weights[dy][dx]
+					t := row[dx*4:]
+					t[0] = uint8(uint16(p[0]) * w[0] >> 8)
+					t[1] = uint8(uint16(p[1]) * w[1] >> 8)
+					t[2] = uint8(uint16(p[2]) * w[2] >> 8)
+					t[3] = 0xFF
+				}
+			}
+		}
+	}
+	return f.out
+}
@@ -0,0 +1,314 @@
+/*
+		gemu - the gameboy emulator
+				<3 m0x
+	    __________________________
+	   |                          |
+	   | .----------------------. |
+	   | |  .----------------.  | |
+	   | |  |                |  | |
+	   | |))|                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  '----------------'  | |
+	   | |__GAME BOY____________/ |
+	   |          ________        |
+	   |    .    (Nintendo)       |
+	   |  _| |_   """"""""   .-.  |
+	   |-[_   _]-       .-. (   ) |
+	   |   |_|         (   ) '-'  |
+	   |    '           '-'   A   |
+	   |                 B        |
+	   |          ___   ___       |
+	   |         (___) (___)  ,., |
+	   |        select start ;:;: |
+	   |                    ,;:;' /
+	   |                   ,:;:'.'
+	   '-----------------------`
+*/
+package render
+
+import (
+	"fmt"
+	"gemu/pkg/joypad"
+	"gemu/pkg/palette"
+	"gemu/pkg/ppu"
+
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+// ActionKind is something the user asked the emulator to do through a hotkey
+type ActionKind int
+
+const (
+	ActionSaveState  = ActionKind(iota) // Save the machine state to Slot
+	ActionLoadState                     // Load the machine state from Slot
+	ActionRewind                        // Rewind while the key is held, see Held
+	ActionButtons                       // The GameBoy buttons being held changed, see Buttons
+	ActionSpeed                         // Change the emulation speed, see Speed
+	ActionPause                         // Pause or resume, see Held
+	ActionAdvance                       // Run a single frame while paused
+	ActionScreenshot                    // Save a screenshot coloured with Palettes, scaled by Scale
+	ActionVideo                         // Start recording video coloured with Palettes, or stop
+)
+
+// Action is a hotkey press, passed on to whoever is running the emulator
+type Action struct {
+	Kind ActionKind
+	Slot int  // Save state slot, 1-9
+	Held bool // For keys that are held down or toggled, if the key went down or came back up
+
+	Buttons joypad.Buttons // All of the GameBoy buttons being held
+	Speed   float64        // Speed multiplier, 0 for as fast as possible
+
+	Palettes ppu.Palettes // The colours the screen is shown in
+	Scale    int          // How much to scale screenshots up by
+}
+
+// buttonKeys maps the keyboard to the GameBoy's buttons
+var buttonKeys = map[sdl.Keycode]joypad.Buttons{
+	sdl.K_UP:     joypad.Up,
+	sdl.K_DOWN:   joypad.Down,
+	sdl.K_LEFT:   joypad.Left,
+	sdl.K_RIGHT:  joypad.Right,
+	sdl.K_x:      joypad.A,
+	sdl.K_z:      joypad.B,
+	sdl.K_RETURN: joypad.Start,
+	sdl.K_RSHIFT: joypad.Select,
+}
+
+// Hotkeys:
+//
+// F1-F9		Load save state slot 1-9
+// Shift+F1-F9	Save state to slot 1-9
+// Backspace	Rewind, while held
+// Tab			Fast-forward as fast as possible, while held
+// - and =		Slow down and speed up, through speeds
+// 0			Back to normal speed
+// P			Pause and resume
+// .			Advance a single frame, pausing first
+// Ctrl+1-8		Resize the window to 1-8x
+// F10			Switch between nearest neighbour and smooth filtering
+// Shift+F10	Switch between integer scaling and filling the window
+// F11			Switch between fullscreen and the window
+// C			Next palette
+// Shift+C		Previous palette
+// G			Pixel grid on and off
+// M			LCD mask on and off
+// B			Step through the amounts of ghosting (motion blur)
+// O			Show and hide the on-screen display
+// F12			Screenshot, at the GameBoy's resolution
+// Shift+F12	Screenshot, at the size the screen is shown
+// V			Start and stop recording video
+var slotKeys = [...]sdl.Keycode{sdl.K_F1, sdl.K_F2, sdl.K_F3, sdl.K_F4, sdl.K_F5, sdl.K_F6, sdl.K_F7, sdl.K_F8, sdl.K_F9}
+
+// speeds are the speed multipliers - and = step through
+var speeds = [...]float64{0.25, 0.5, 1, 2, 4, 8}
+
+// normalSpeed is the index of 1x in speeds
+const normalSpeed = 2
+
+// controls turns key presses into actions, keeping track of the keys that are held down
+type controls struct {
+	actions chan<- Action
+	lcd     *screen
+	osd     *OSD
+
+	buttons     joypad.Buttons
+	speed       int // Index into speeds
+	fastForward bool
+	paused      bool
+
+	pending []Action // Waiting for room in actions
+}
+
+func newControls(actions chan<- Action, lcd *screen, osd *OSD) *controls {
+	return &controls{actions: actions, lcd: lcd, osd: osd, speed: normalSpeed}
+}
+
+// key handles a key going down or coming back up, key repeats are expected to be filtered out
+func (c *controls) key(key sdl.Keysym, pressed bool) {
+	if b, ok := buttonKeys[key.Sym]; ok {
+		if pressed {
+			c.buttons |= b
+		} else {
+			c.buttons &^= b
+		}
+		c.send(Action{Kind: ActionButtons, Buttons: c.buttons})
+		return
+	}
+
+	switch key.Sym {
+	case sdl.K_BACKSPACE:
+		c.send(Action{Kind: ActionRewind, Held: pressed})
+		return
+	case sdl.K_TAB:
+		c.fastForward = pressed
+		c.sendSpeed()
+		return
+	}
+	if !pressed {
+		return
+	}
+
+	for i, k := range slotKeys {
+		if key.Sym != k {
+			continue
+		}
+		if key.Mod&sdl.KMOD_SHIFT != 0 {
+			c.send(Action{Kind: ActionSaveState, Slot: i + 1})
+		} else {
+			c.send(Action{Kind: ActionLoadState, Slot: i + 1})
+		}
+		return
+	}
+
+	if key.Mod&sdl.KMOD_CTRL != 0 && key.Sym >= sdl.K_1 && key.Sym <= sdl.K_8 {
+		c.lcd.setScale(int(key.Sym-sdl.K_1) + 1)
+		return
+	}
+
+	var err error
+	switch key.Sym {
+	case sdl.K_F10:
+		if key.Mod&sdl.KMOD_SHIFT != 0 {
+			c.lcd.toggleIntegerScale()
+		} else {
+			err = c.lcd.toggleSmooth()
+		}
+	case sdl.K_F11:
+		err = c.lcd.toggleFullscreen()
+	case sdl.K_F12:
+		scale := 1
+		if key.Mod&sdl.KMOD_SHIFT != 0 {
+			scale, err = c.lcd.scale()
+		}
+		c.send(Action{Kind: ActionScreenshot, Palettes: c.lcd.palettes[c.lcd.palette].Palettes, Scale: scale})
+	case sdl.K_c:
+		step := 1
+		if key.Mod&sdl.KMOD_SHIFT != 0 {
+			step = -1
+		}
+		var p palette.Palette
+		if p, err = c.lcd.cyclePalette(step); err == nil {
+			c.notify("Palette: " + p.Name)
+		}
+	case sdl.K_v:
+		c.send(Action{Kind: ActionVideo, Palettes: c.lcd.palettes[c.lcd.palette].Palettes})
+	case sdl.K_g:
+		if err = c.lcd.toggleGrid(); err == nil {
+			c.notify("Grid " + onOff(c.lcd.filters.grid))
+		}
+	case sdl.K_m:
+		if err = c.lcd.toggleMask(); err == nil {
+			c.notify("LCD mask " + onOff(c.lcd.filters.mask))
+		}
+	case sdl.K_b:
+		c.notify(fmt.Sprintf("Ghosting: %g", c.lcd.cycleGhosting()))
+	case sdl.K_o:
+		c.osd.Toggle()
+	case sdl.K_MINUS:
+		if c.speed > 0 {
+			c.speed--
+			c.sendSpeed()
+		}
+	case sdl.K_EQUALS:
+		if c.speed < len(speeds)-1 {
+			c.speed++
+			c.sendSpeed()
+		}
+	case sdl.K_0:
+		c.speed = normalSpeed
+		c.sendSpeed()
+	case sdl.K_p:
+		c.paused = !c.paused
+		c.send(Action{Kind: ActionPause, Held: c.paused})
+	case sdl.K_PERIOD:
+		if !c.paused {
+			c.paused = true
+			c.send(Action{Kind: ActionPause, Held: true})
+		}
+		c.send(Action{Kind: ActionAdvance})
+	}
+	if err != nil {
+		fmt.Println("[!] " + err.Error())
+	}
+}
+
+// send passes an action on without blocking, so a busy emulator can't hang the window. Actions that set
+// some state wait for room, keeping only the latest of each kind, and one-off actions are dropped.
+func (c *controls) send(a Action) {
+	switch a.Kind {
+	case ActionButtons, ActionSpeed, ActionRewind, ActionPause:
+		for i := range c.pending {
+			if c.pending[i].Kind == a.Kind {
+				c.pending = append(c.pending[:i], c.pending[i+1:]...)
+				break
+			}
+		}
+		c.pending = append(c.pending, a)
+		c.flush()
+		return
+	}
+
+	// Don't let a one-off action overtake the state it was pressed in
+	c.flush()
+	if len(c.pending) == 0 {
+		select {
+		case c.actions <- a:
+			return
+		default:
+		}
+	}
+	c.notify("Busy, hotkey ignored")
+}
+
+// flush sends as many of the pending actions as there's room for
+func (c *controls) flush() {
+	for len(c.pending) > 0 {
+		select {
+		case c.actions <- c.pending[0]:
+			c.pending = c.pending[1:]
+		default:
+			return
+		}
+	}
+}
+
+// notify tells the user about something a hotkey did
+func (c *controls) notify(text string) {
+	fmt.Println(text)
+	c.osd.Message("%s", text)
+}
+
+// onOff returns "on" or "off"
+func onOff(on bool) string {
+	if on {
+		return "on"
+	}
+	return "off"
+}
+
+// sendSpeed sends the speed the GameBoy should be running at
+func (c *controls) sendSpeed() {
+	speed := speeds[c.speed]
+	if c.fastForward {
+		speed = 0
+	}
+	c.send(Action{Kind: ActionSpeed, Speed: speed})
+}
+
+// status returns the text of the speed indicator, empty at normal speed
+func (c *controls) status() string {
+	switch {
+	case c.paused:
+		return "PAUSED"
+	case c.fastForward:
+		return "MAX"
+	case c.speed != normalSpeed:
+		return fmt.Sprintf("%gx", speeds[c.speed])
+	}
+	return ""
+}
@@ -0,0 +1,344 @@
+/*
+		gemu - the gameboy emulator
+				<3 m0x
+	    __________________________
+	   |                          |
+	   | .----------------------. |
+	   | |  .----------------.  | |
+	   | |  |                |  | |
+	   | |))|                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  '----------------'  | |
+	   | |__GAME BOY____________/ |
+	   |          ________        |
+	   |    .    (Nintendo)       |
+	   |  _| |_   """"""""   .-.  |
+	   |-[_   _]-       .-. (   ) |
+	   |   |_|         (   ) '-'  |
+	   |    '           '-'   A   |
+	   |                 B        |
+	   |          ___   ___       |
+	   |         (___) (___)  ,., |
+	   |        select start ;:;: |
+	   |                    ,;:;' /
+	   |                   ,:;:'.'
+	   '-----------------------`
+*/
+package render
+
+import (
+	"fmt"
+	"gemu/pkg/ppu"
+	"sync"
+	"time"
+
+	"github.com/veandco/go-sdl2/sdl"
+	"github.com/veandco/go-sdl2/ttf"
+)
+
+/*	On-screen display
+
+The OSD is drawn over the game, in the window's corners so it covers as little of it as it can:
+
+Top left		Emulation FPS and speed, the link cable's status, and if video is being recorded
+Top right		Pause and fast-forward icons, and the speed when it isn't 1x
+Bottom left		Messages, such as "Saved state to slot 3", which go away after a few seconds
+
+Messages and stats come from whoever is running the emulator, from any goroutine. The OSD can be
+hidden with a hotkey, but messages are still shown as they're the only feedback hotkeys give.
+*/
+
+// fontPath is the font text is drawn in, relative to where gemu is run from
+const fontPath = "./fonts/GameBoy.ttf"
+
+// messageTime is how long messages are shown for
+const messageTime = 3 * time.Second
+
+// maxMessages is how many messages are shown at once, older ones are dropped
+const maxMessages = 4
+
+// frameRate is the frame rate of real hardware, ~59.73Hz
+const frameRate = 4194304.0 / ppu.CyclesPerFrame
+
+// Link is the state of the link cable
+type Link int
+
+const (
+	LinkNone         = Link(iota) // Nothing is plugged in
+	LinkIdle                      // Connected, but nothing is being sent
+	LinkTransferring              // A byte is being sent
+)
+
+// String returns how the link status is shown
+func (l Link) String() string {
+	switch l {
+	case LinkIdle:
+		return "LINK"
+	case LinkTransferring:
+		return "LINK <>"
+	}
+	return "NO LINK"
+}
+
+// Stats are how the emulator is doing, shown in the top left
+type Stats struct {
+	FPS       float64 // GameBoy frames emulated per second
+	Link      Link
+	Recording bool // If video is being recorded
+}
+
+// OSD is the on-screen display. Its methods are safe to call from any goroutine.
+type OSD struct {
+	mu       sync.Mutex
+	visible  bool
+	stats    Stats
+	messages []message
+
+	// Only touched by the render loop
+	font   *ttf.Font
+	labels map[string]*label
+}
+
+// message is a line of text shown until a deadline
+type message struct {
+	text  string
+	until time.Time
+}
+
+// NewOSD returns an on-screen display, shown or hidden to begin with
+func NewOSD(visible bool) *OSD {
+	return &OSD{visible: visible, labels: make(map[string]*label)}
+}
+
+// Message shows a message for a few seconds
+func (o *OSD) Message(format string, args ...interface{}) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.messages = append(o.messages, message{text: fmt.Sprintf(format, args...), until: time.Now().Add(messageTime)})
+	if len(o.messages) > maxMessages {
+		o.messages = o.messages[len(o.messages)-maxMessages:]
+	}
+}
+
+// SetStats updates the stats shown
+func (o *OSD) SetStats(stats Stats) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.stats = stats
+}
+
+// Toggle shows or hides the OSD, returning if it's now shown
+func (o *OSD) Toggle() bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.visible = !o.visible
+	return o.visible
+}
+
+// open loads the OSD's font. Without the font there's nothing to draw text with, which isn't worth
+// failing over, so only the icons are shown.
+func (o *OSD) open() {
+	font, err := ttf.OpenFont(fontPath, 16)
+	if err != nil {
+		fmt.Printf("[!] failed to load %s, there won't be any on screen text - %s\n", fontPath, err)
+		return
+	}
+	o.font = font
+}
+
+// close frees the OSD's textures and font
+func (o *OSD) close() {
+	for _, l := range o.labels {
+		l.destroy()
+	}
+	o.labels = make(map[string]*label)
+	if o.font != nil {
+		o.font.Close()
+		o.font = nil
+	}
+}
+
+// osdMargin is the gap between the OSD and the edges of the window, and around its text
+const osdMargin = 8
+
+// draw draws the OSD, status is the speed indicator text from the hotkeys
+func (o *OSD) draw(renderer *sdl.Renderer, status string, paused, fastForward bool) error {
+	o.mu.Lock()
+	visible, stats := o.visible, o.stats
+	now := time.Now()
+	for len(o.messages) > 0 && now.After(o.messages[0].until) {
+		o.messages = o.messages[1:]
+	}
+	messages := make([]string, len(o.messages))
+	for i, m := range o.messages {
+		messages[i] = m.text
+	}
+	o.mu.Unlock()
+
+	w, h, err := renderer.GetOutputSize()
+	if err != nil {
+		return err
+	}
+	renderer.SetDrawBlendMode(sdl.BLENDMODE_BLEND)
+
+	// Messages, newest at the bottom
+	y := h - osdMargin
+	for i := len(messages) - 1; i >= 0; i-- {
+		l, err := o.label(renderer, messages[i])
+		if err != nil {
+			return err
+		}
+		if l == nil {
+			break
+		}
+		y -= l.h + osdMargin
+		if err := l.draw(renderer, osdMargin, y); err != nil {
+			return err
+		}
+	}
+	if !visible {
+		o.prune(messages)
+		return nil
+	}
+
+	// Stats
+	lines := []string{fmt.Sprintf("%.1f FPS %3.0f%%", stats.FPS, stats.FPS/frameRate*100), stats.Link.String()}
+	if stats.Recording {
+		lines = append(lines, "REC")
+	}
+	y = osdMargin
+	for _, text := range lines {
+		l, err := o.label(renderer, text)
+		if err != nil {
+			return err
+		}
+		if l == nil {
+			break
+		}
+		if err := l.draw(renderer, osdMargin, y); err != nil {
+			return err
+		}
+		y += l.h + 2*osdMargin
+	}
+
+	// Status, with its icon to its left
+	x := w - osdMargin
+	if status != "" {
+		l, err := o.label(renderer, status)
+		if err != nil {
+			return err
+		}
+		if l != nil {
+			x -= l.w + 2*osdMargin
+			if err := l.draw(renderer, x, osdMargin); err != nil {
+				return err
+			}
+		}
+	}
+	const icon = 24
+	switch {
+	case paused:
+		drawPause(renderer, x-icon-osdMargin, osdMargin, icon)
+	case fastForward:
+		drawFastForward(renderer, x-icon-osdMargin, osdMargin, icon)
+	}
+
+	o.prune(append(append(messages, status), lines...))
+	return nil
+}
+
+// label returns the label showing text, nil without a font
+func (o *OSD) label(renderer *sdl.Renderer, text string) (*label, error) {
+	if o.font == nil {
+		return nil, nil
+	}
+	if l, ok := o.labels[text]; ok {
+		return l, nil
+	}
+	l, err := newLabel(renderer, o.font, text)
+	if err != nil {
+		return nil, err
+	}
+	o.labels[text] = l
+	return l, nil
+}
+
+// prune frees the labels that aren't showing any of texts, so they don't pile up as the stats change
+func (o *OSD) prune(texts []string) {
+	keep := make(map[string]bool, len(texts))
+	for _, t := range texts {
+		keep[t] = true
+	}
+	for t, l := range o.labels {
+		if !keep[t] {
+			l.destroy()
+			delete(o.labels, t)
+		}
+	}
+}
+
+// label is a line of text, rendered to a texture once
+type label struct {
+	texture *sdl.Texture
+	w, h    int32
+}
+
+func newLabel(renderer *sdl.Renderer, font *ttf.Font, text string) (*label, error) {
+	surface, err := font.RenderUTF8Blended(text, sdl.Color{R: 0xFF, G: 0xFF, B: 0xFF, A: 0xFF})
+	if err != nil {
+		return nil, err
+	}
+	defer surface.Free()
+	texture, err := renderer.CreateTextureFromSurface(surface)
+	if err != nil {
+		return nil, err
+	}
+	return &label{texture: texture, w: surface.W, h: surface.H}, nil
+}
+
+// draw draws the label on a dark box, with the box's top left corner at x, y
+func (l *label) draw(renderer *sdl.Renderer, x, y int32) error {
+	box := sdl.Rect{X: x, Y: y, W: l.w + 2*osdMargin, H: l.h + osdMargin}
+	renderer.SetDrawColor(0, 0, 0, 0xA0)
+	renderer.FillRect(&box)
+	return renderer.Copy(l.texture, nil, &sdl.Rect{X: x + osdMargin, Y: y + osdMargin/2, W: l.w, H: l.h})
+}
+
+func (l *label) destroy() {
+	l.texture.Destroy()
+}
+
+// drawPause draws a pause icon, two bars, in a size by size square
+func drawPause(renderer *sdl.Renderer, x, y, size int32) {
+	renderer.SetDrawColor(0, 0, 0, 0xA0)
+	renderer.FillRect(&sdl.Rect{X: x, Y: y, W: size, H: size})
+	renderer.SetDrawColor(0xFF, 0xFF, 0xFF, 0xFF)
+	bar := size / 4
+	renderer.FillRect(&sdl.Rect{X: x + bar, Y: y + bar/2, W: bar, H: size - bar})
+	renderer.FillRect(&sdl.Rect{X: x + size - 2*bar, Y: y + bar/2, W: bar, H: size - bar})
+}
+
+// drawFastForward draws a fast-forward icon, two triangles, in a size by size square.
+// The renderer can only fill rectangles, so the triangles are built a row at a time.
+func drawFastForward(renderer *sdl.Renderer, x, y, size int32) {
+	renderer.SetDrawColor(0, 0, 0, 0xA0)
+	renderer.FillRect(&sdl.Rect{X: x, Y: y, W: size, H: size})
+	renderer.SetDrawColor(0xFF, 0xFF, 0xFF, 0xFF)
+	pad := size / 6
+	height := size - 2*pad
+	half := (size - 2*pad) / 2
+	for row := int32(0); row < height; row++ {
+		// Rows are widest in the middle, where the triangles point
+		width := row
+		if row > height/2 {
+			width = height - row
+		}
+		width = width * half / (height / 2)
+		renderer.FillRect(&sdl.Rect{X: x + pad, Y: y + pad + row, W: width, H: 1})
+		renderer.FillRect(&sdl.Rect{X: x + pad + half, Y: y + pad + row, W: width, H: 1})
+	}
+}
@@ -32,6 +32,7 @@ package render
 
 import (
 	"fmt"
+	"gemu/pkg/ppu"
 	"os"
 	"strings"
 
@@ -58,8 +59,9 @@ func Init() error {
 	return nil
 }
 
-// Run starts the rendering loop, which handles SDL events and renders the gameboy screen
-func Run(frame chan *sdl.Surface, renderStopped chan struct{}, stopRender chan struct{}) error {
+// Run starts the rendering loop, which handles SDL events and renders the gameboy screen.
+// Hotkey presses are sent to actions, which has to be kept drained. osd is drawn over the game.
+func Run(opts Options, osd *OSD, frame chan *ppu.Frame, actions chan<- Action, renderStopped chan struct{}, stopRender chan struct{}) error {
 	// Check if we are running in WSL2 - hardware acceleration is not currently supported
 	wsl := false
 	ver, err := os.ReadFile("/proc/version")
@@ -73,19 +75,21 @@ func Run(frame chan *sdl.Surface, renderStopped chan struct{}, stopRender chan s
 	//runtime.LockOSThread()
 	rendering := true
 
-	// Define rendering FPS limits
+	// Without V-Sync, frames are presented at a fixed rate instead of the display's.
 	// 60hz is pretty close to the 59.73Hz vertical sync of the Gameboy
 	fps := uint64(60)         // Frame per second maxmimum
 	tpp := uint64(1000 / fps) // Ticks per frame
 
-	// Create SDL2 window
-	window, err := sdl.CreateWindow("gemu", sdl.WINDOWPOS_UNDEFINED, sdl.WINDOWPOS_UNDEFINED, 600, 800, sdl.WINDOW_SHOWN)
+	// Create SDL2 window, newScreen sizes it
+	window, err := sdl.CreateWindow("gemu", sdl.WINDOWPOS_UNDEFINED, sdl.WINDOWPOS_UNDEFINED, ppu.ScreenWidth, ppu.ScreenHeight, sdl.WINDOW_SHOWN|sdl.WINDOW_RESIZABLE)
 	if err != nil {
 		return err
 	}
 
-	// Create SDL2 renderer for our window, without V-Sync (since we want to control the FPS)
-	render_flags := sdl.RENDERER_ACCELERATED
+	// Create SDL2 renderer for our window. The GameBoy paces itself, so frames are presented in time
+	// with the display through V-Sync, however fast or slow the GameBoy is running.
+	vsync := !wsl
+	render_flags := sdl.RENDERER_ACCELERATED | sdl.RENDERER_PRESENTVSYNC
 	if wsl {
 		render_flags = sdl.RENDERER_SOFTWARE
 	}
@@ -95,8 +99,19 @@ func Run(frame chan *sdl.Surface, renderStopped chan struct{}, stopRender chan s
 		return err
 	}
 
-	// Define our SDL Surface, which will represent the rendered version of the Gameboy's VRAM
-	frameSurf := new(sdl.Surface)
+	// The GameBoy's screen, which frames are streamed to
+	lcd, err := newScreen(window, renderer, opts)
+	if err != nil {
+		return err
+	}
+	defer lcd.destroy()
+
+	// Hotkeys and the GameBoy's buttons
+	keys := newControls(actions, lcd, osd)
+
+	// The on-screen display, over the game
+	osd.open()
+	defer osd.close()
 
 	// Stop channel monitoring
 	go func(stopped chan struct{}, stop chan struct{}) {
@@ -118,36 +133,44 @@ func Run(frame chan *sdl.Surface, renderStopped chan struct{}, stopRender chan s
 		// Make note of when this frame iternation started, so we can control FPS
 		frameStart := sdl.GetTicks64()
 
-		// Get the next frame from the emulator, in a non-blocking way
-		select {
-		case f := <-frame:
-			frameSurf = f
-		default:
-			//fmt.Println("[RENDER] NO FRAME IN CHAN")
+		// Get the newest frame from the emulator, in a non-blocking way. When it's running faster than
+		// the display, the frames in between are dropped.
+		var latest *ppu.Frame
+		for drained := false; !drained; {
+			select {
+			case f := <-frame:
+				latest = f
+			default:
+				drained = true
+			}
 		}
-
-		// Create our frame texture
-		texture, err := renderer.CreateTextureFromSurface(frameSurf)
-		if err != nil {
-			return err
+		if latest != nil {
+			if err := lcd.update(latest); err != nil {
+				return err
+			}
 		}
-		texture.SetBlendMode(sdl.BLENDMODE_BLEND)
-		texture.SetAlphaMod(200)
 
-		// Update our renderer with the new frame texture
+		// Letterbox the screen in black
 		renderer.SetDrawColor(0, 0, 0, 255)
 		renderer.Clear()
-		renderer.Copy(texture, nil, &sdl.Rect{X: 150, Y: 250, W: 200, H: 20})
+		if err := lcd.draw(); err != nil {
+			return err
+		}
+		if err := osd.draw(renderer, keys.status(), keys.paused, keys.fastForward); err != nil {
+			return err
+		}
 		renderer.Present()
-		texture.Destroy()
 
 		// How long did that take? Do we need to delay to maintain 60fps
 		frameTime := sdl.GetTicks64() - frameStart
-		if frameTime < tpp {
+		if !vsync && frameTime < tpp {
 			//fmt.Println("Render delay to maintain 60fps")
 			sdl.Delay(uint32(tpp - frameTime))
 		}
 
+		// Pass on the hotkeys that were waiting for the emulator to catch up
+		keys.flush()
+
 		// Event handling
 		// TODO: Event handling should probably go in its own routine...
 		for event := sdl.PollEvent(); event != nil; event = sdl.PollEvent() {
@@ -164,8 +187,20 @@ func Run(frame chan *sdl.Surface, renderStopped chan struct{}, stopRender chan s
 					close(renderStopped)
 					rendering = false
 				}
+
+				if t.Repeat == 0 {
+					keys.key(t.Keysym, t.State == sdl.PRESSED)
+				}
 			}
 		}
 	}
 	return nil
 }
+
+// convert colours a frame's shades into ABGR8888 pixels
+func convert(f *ppu.Frame, p *ppu.Palettes, pixels []byte) {
+	for i, shade := range f {
+		c := p.Color(shade)
+		pixels[i*4], pixels[i*4+1], pixels[i*4+2], pixels[i*4+3] = c.R, c.G, c.B, c.A
+	}
+}
@@ -0,0 +1,295 @@
+/*
+		gemu - the gameboy emulator
+				<3 m0x
+	    __________________________
+	   |                          |
+	   | .----------------------. |
+	   | |  .----------------.  | |
+	   | |  |                |  | |
+	   | |))|                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  '----------------'  | |
+	   | |__GAME BOY____________/ |
+	   |          ________        |
+	   |    .    (Nintendo)       |
+	   |  _| |_   """"""""   .-.  |
+	   |-[_   _]-       .-. (   ) |
+	   |   |_|         (   ) '-'  |
+	   |    '           '-'   A   |
+	   |                 B        |
+	   |          ___   ___       |
+	   |         (___) (___)  ,., |
+	   |        select start ;:;: |
+	   |                    ,;:;' /
+	   |                   ,:;:'.'
+	   '-----------------------`
+*/
+package render
+
+import (
+	"gemu/pkg/palette"
+	"gemu/pkg/ppu"
+	"unsafe"
+
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+// Options are how the GameBoy's screen is shown
+type Options struct {
+	Scale        int  // Window size, as a multiple of the GameBoy's screen (1-8)
+	Fullscreen   bool // Start fullscreen
+	Smooth       bool // Smooth (linear) filtering when scaling, instead of nearest neighbour
+	IntegerScale bool // Only scale by whole multiples, letterboxing whatever is left over
+
+	// The palette to start with. The palette hotkeys cycle through it and the builtin palettes.
+	Palette palette.Palette
+
+	// Display filters, see filter.go
+	Ghosting float64 // How much of the last frame is left in the next, from 0 (off) to MaxPersistence
+	Grid     bool    // Show the gaps between pixels
+	LCDMask  bool    // Tint the pixels like an LCD's subpixels
+}
+
+// DefaultOptions are the Options used when nothing else is asked for
+var DefaultOptions = Options{Scale: 4, IntegerScale: true, Palette: palette.Builtin[0]}
+
+// MaxScale is the biggest window scale
+const MaxScale = 8
+
+// screen is the GameBoy's screen, drawn into the window through a streaming texture
+type screen struct {
+	window   *sdl.Window
+	renderer *sdl.Renderer
+
+	texture *sdl.Texture
+	pixels  []byte    // The frame as ABGR8888, before it's filtered
+	frame   ppu.Frame // The frame shown, kept to recolour it when the palette changes
+
+	// The filters, and the filtered pixels the texture is updated from
+	filters *filters
+	shown   []byte
+	width   int
+
+	// The palettes to pick from, and the one in use
+	palettes []palette.Palette
+	palette  int
+
+	smooth       bool
+	integerScale bool
+	fullscreen   bool
+}
+
+// newScreen creates the screen's texture, and sizes the window
+func newScreen(window *sdl.Window, renderer *sdl.Renderer, opts Options) (*screen, error) {
+	s := &screen{
+		window:       window,
+		renderer:     renderer,
+		pixels:       make([]byte, ppu.ScreenWidth*ppu.ScreenHeight*4),
+		smooth:       opts.Smooth,
+		integerScale: opts.IntegerScale,
+		palettes:     palette.Builtin,
+		filters:      newFilters(opts.Ghosting, opts.Grid, opts.LCDMask),
+	}
+
+	// A palette loaded from a file joins the builtin ones
+	s.palette = -1
+	for i, p := range s.palettes {
+		if p == opts.Palette {
+			s.palette = i
+		}
+	}
+	if s.palette < 0 {
+		s.palettes = append(append([]palette.Palette(nil), palette.Builtin...), opts.Palette)
+		s.palette = len(s.palettes) - 1
+	}
+
+	window.SetMinimumSize(ppu.ScreenWidth, ppu.ScreenHeight)
+	s.setScale(opts.Scale)
+	if opts.Fullscreen {
+		if err := s.toggleFullscreen(); err != nil {
+			return nil, err
+		}
+	}
+
+	// Until the first frame arrives it's blank
+	s.recolor()
+	s.filter(true)
+	if err := s.createTexture(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// createTexture (re)creates the texture, which picks up the filtering in use
+func (s *screen) createTexture() error {
+	quality := "nearest"
+	if s.smooth {
+		quality = "linear"
+	}
+	sdl.SetHint(sdl.HINT_RENDER_SCALE_QUALITY, quality)
+
+	if s.texture != nil {
+		s.texture.Destroy()
+	}
+	scale := int32(s.filters.scale())
+	texture, err := s.renderer.CreateTexture(uint32(sdl.PIXELFORMAT_ABGR8888), sdl.TEXTUREACCESS_STREAMING, ppu.ScreenWidth*scale, ppu.ScreenHeight*scale)
+	if err != nil {
+		return err
+	}
+	s.texture = texture
+	return s.upload()
+}
+
+// update shows a new frame
+func (s *screen) update(f *ppu.Frame) error {
+	s.frame = *f
+	s.recolor()
+	s.filter(true)
+	return s.upload()
+}
+
+// recolor converts the frame to pixels with the palette in use
+func (s *screen) recolor() {
+	convert(&s.frame, &s.palettes[s.palette].Palettes, s.pixels)
+}
+
+// filter runs the filters over the pixels, newFrame is false when it's the same frame again
+func (s *screen) filter(newFrame bool) {
+	s.shown, s.width = s.filters.apply(s.pixels, newFrame)
+}
+
+// cyclePalette switches to the next palette, or the previous one when step is -1
+func (s *screen) cyclePalette(step int) (palette.Palette, error) {
+	s.palette = (s.palette + step + len(s.palettes)) % len(s.palettes)
+	s.recolor()
+
+	// The old colours shouldn't linger
+	s.filters.reset()
+	s.filter(true)
+	return s.palettes[s.palette], s.upload()
+}
+
+// toggleGrid switches the pixel grid on or off
+func (s *screen) toggleGrid() error {
+	s.filters.toggleGrid()
+	s.filter(false)
+	return s.createTexture()
+}
+
+// toggleMask switches the LCD mask on or off
+func (s *screen) toggleMask() error {
+	s.filters.toggleMask()
+	s.filter(false)
+	return s.createTexture()
+}
+
+// cycleGhosting steps through the amounts of ghosting, returning the one now in use
+func (s *screen) cycleGhosting() float64 {
+	next := ghostingSteps[0]
+	for _, g := range ghostingSteps {
+		if g > s.filters.persistence {
+			next = g
+			break
+		}
+	}
+	s.filters.setPersistence(next)
+	return next
+}
+
+// ghostingSteps are the amounts of ghosting the hotkey steps through
+var ghostingSteps = [...]float64{0, 0.3, 0.5, 0.7}
+
+// upload copies the filtered pixels to the texture
+func (s *screen) upload() error {
+	return s.texture.Update(nil, unsafe.Pointer(&s.shown[0]), s.width*4)
+}
+
+// draw draws the screen, as big as it fits in the window, centred
+func (s *screen) draw() error {
+	w, h, err := s.renderer.GetOutputSize()
+	if err != nil {
+		return err
+	}
+	dst := fit(w, h, s.integerScale)
+	return s.renderer.Copy(s.texture, nil, &dst)
+}
+
+// fit returns where the screen goes in a w by h window, keeping its aspect ratio. With integerScale,
+// it's scaled by the biggest whole multiple that fits, unless the window is too small for even 1x.
+func fit(w, h int32, integerScale bool) sdl.Rect {
+	var sw, sh int32
+	if scale := min32(w/ppu.ScreenWidth, h/ppu.ScreenHeight); integerScale && scale >= 1 {
+		sw, sh = ppu.ScreenWidth*scale, ppu.ScreenHeight*scale
+	} else if w*ppu.ScreenHeight > h*ppu.ScreenWidth {
+		// The window is wider than the screen, so the height decides
+		sw, sh = h*ppu.ScreenWidth/ppu.ScreenHeight, h
+	} else {
+		sw, sh = w, w*ppu.ScreenHeight/ppu.ScreenWidth
+	}
+	return sdl.Rect{X: (w - sw) / 2, Y: (h - sh) / 2, W: sw, H: sh}
+}
+
+func min32(a, b int32) int32 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// setScale resizes the window to a multiple of the GameBoy's screen
+func (s *screen) setScale(scale int) {
+	if scale < 1 {
+		scale = 1
+	} else if scale > MaxScale {
+		scale = MaxScale
+	}
+	s.window.SetSize(ppu.ScreenWidth*int32(scale), ppu.ScreenHeight*int32(scale))
+}
+
+// scale returns the biggest whole multiple of the GameBoy's screen that fits where it's shown
+func (s *screen) scale() (int, error) {
+	w, h, err := s.renderer.GetOutputSize()
+	if err != nil {
+		return 1, err
+	}
+	scale := int(fit(w, h, true).W / ppu.ScreenWidth)
+	if scale < 1 {
+		scale = 1
+	} else if scale > MaxScale {
+		scale = MaxScale
+	}
+	return scale, nil
+}
+
+// toggleFullscreen switches between fullscreen and the window
+func (s *screen) toggleFullscreen() error {
+	var flags uint32
+	if !s.fullscreen {
+		flags = sdl.WINDOW_FULLSCREEN_DESKTOP
+	}
+	if err := s.window.SetFullscreen(flags); err != nil {
+		return err
+	}
+	s.fullscreen = !s.fullscreen
+	return nil
+}
+
+// toggleSmooth switches between nearest neighbour and smooth filtering
+func (s *screen) toggleSmooth() error {
+	s.smooth = !s.smooth
+	return s.createTexture()
+}
+
+// toggleIntegerScale switches between whole multiples and filling as much of the window as possible
+func (s *screen) toggleIntegerScale() {
+	s.integerScale = !s.integerScale
+}
+
+// destroy frees the texture
+func (s *screen) destroy() {
+	s.texture.Destroy()
+}
@@ -0,0 +1,203 @@
+/*
+		gemu - the gameboy emulator
+				<3 m0x
+	    __________________________
+	   |                          |
+	   | .----------------------. |
+	   | |  .----------------.  | |
+	   | |  |                |  | |
+	   | |))|                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  '----------------'  | |
+	   | |__GAME BOY____________/ |
+	   |          ________        |
+	   |    .    (Nintendo)       |
+	   |  _| |_   """"""""   .-.  |
+	   |-[_   _]-       .-. (   ) |
+	   |   |_|         (   ) '-'  |
+	   |    '           '-'   A   |
+	   |                 B        |
+	   |          ___   ___       |
+	   |         (___) (___)  ,., |
+	   |        select start ;:;: |
+	   |                    ,;:;' /
+	   |                   ,:;:'.'
+	   '-----------------------`
+*/
+package rewind
+
+import (
+	"bytes"
+	"compress/flate"
+	"io"
+)
+
+/*	Rewind buffer
+
+Snapshots of the machine state are kept in a ring with a fixed number of slots. Only the newest
+snapshot is kept whole, every older one is stored as the XOR of it and the snapshot after it,
+flate compressed. Most of the machine doesn't change between snapshots, so the XOR is mostly
+zeros and compresses down to a few KiB.
+
+Going back, the newest snapshot is XORed with the delta before it to get the older one back.
+Going forward in time only ever adds a delta, and the oldest delta can be dropped without
+touching any of the others, so the buffer never has to decompress anything while recording.
+*/
+
+// delta is an older snapshot, stored as the difference to the snapshot after it
+type delta struct {
+	frame uint64
+	size  int    // Length of the snapshot, which can differ from the one after it
+	data  []byte // Compressed XOR of the two snapshots
+}
+
+// Buffer is a ring of machine state snapshots, oldest first
+type Buffer struct {
+	// The deltas, in a ring starting at start
+	deltas []delta
+	start  int
+	count  int
+
+	// The newest snapshot, whole
+	latest      []byte
+	latestFrame uint64
+	hasLatest   bool
+
+	// Reused between pushes
+	xor []byte
+	buf bytes.Buffer
+	fw  *flate.Writer
+}
+
+// New creates a buffer holding up to size snapshots
+func New(size int) *Buffer {
+	if size < 1 {
+		size = 1
+	}
+	fw, _ := flate.NewWriter(nil, flate.BestSpeed)
+	return &Buffer{deltas: make([]delta, size-1), fw: fw}
+}
+
+// Push adds the snapshot taken at frame as the newest one, dropping the oldest if the buffer is full.
+// The buffer keeps its own copy of state.
+func (b *Buffer) Push(frame uint64, state []byte) error {
+	if b.hasLatest && len(b.deltas) > 0 {
+		d := delta{frame: b.latestFrame, size: len(b.latest)}
+
+		b.xor = xorInto(b.xor, b.latest, state)
+		b.buf.Reset()
+		b.fw.Reset(&b.buf)
+		if _, err := b.fw.Write(b.xor); err != nil {
+			return err
+		}
+		if err := b.fw.Close(); err != nil {
+			return err
+		}
+		d.data = append([]byte(nil), b.buf.Bytes()...)
+
+		if b.count == len(b.deltas) {
+			b.start = (b.start + 1) % len(b.deltas)
+			b.count--
+		}
+		b.deltas[(b.start+b.count)%len(b.deltas)] = d
+		b.count++
+	}
+
+	b.latest = append(b.latest[:0], state...)
+	b.latestFrame = frame
+	b.hasLatest = true
+	return nil
+}
+
+// Latest returns the newest snapshot and the frame it was taken at, without removing it.
+// The snapshot belongs to the buffer and is only valid until the next Push or Pop.
+func (b *Buffer) Latest() (frame uint64, state []byte, ok bool) {
+	return b.latestFrame, b.latest, b.hasLatest
+}
+
+// Pop removes the newest snapshot, making the one before it the newest
+func (b *Buffer) Pop() error {
+	if !b.hasLatest {
+		return nil
+	}
+	if b.count == 0 {
+		b.hasLatest = false
+		b.latest = b.latest[:0]
+		return nil
+	}
+
+	i := (b.start + b.count - 1) % len(b.deltas)
+	d := b.deltas[i]
+	b.deltas[i] = delta{}
+	b.count--
+
+	b.buf.Reset()
+	if _, err := io.Copy(&b.buf, flate.NewReader(bytes.NewReader(d.data))); err != nil {
+		return err
+	}
+	b.latest = xorInto(b.latest, b.latest, b.buf.Bytes())[:d.size]
+	b.latestFrame = d.frame
+	return nil
+}
+
+// Len returns the number of snapshots in the buffer
+func (b *Buffer) Len() int {
+	if !b.hasLatest {
+		return 0
+	}
+	return b.count + 1
+}
+
+// Cap returns the number of snapshots the buffer can hold
+func (b *Buffer) Cap() int {
+	return len(b.deltas) + 1
+}
+
+// Bytes returns roughly how much memory the snapshots take up
+func (b *Buffer) Bytes() int {
+	n := len(b.latest)
+	for i := 0; i < b.count; i++ {
+		n += len(b.deltas[(b.start+i)%len(b.deltas)].data)
+	}
+	return n
+}
+
+// Reset empties the buffer
+func (b *Buffer) Reset() {
+	for i := range b.deltas {
+		b.deltas[i] = delta{}
+	}
+	b.start, b.count = 0, 0
+	b.latest = b.latest[:0]
+	b.hasLatest = false
+}
+
+// xorInto sets dst to a XOR b, as long as the longer of the two, padding the shorter one with zeros
+func xorInto(dst, a, b []byte) []byte {
+	n := len(a)
+	if len(b) > n {
+		n = len(b)
+	}
+	if cap(dst) < n {
+		grown := make([]byte, n)
+		copy(grown, dst)
+		dst = grown
+	}
+	dst = dst[:n]
+
+	for i := 0; i < n; i++ {
+		var x, y byte
+		if i < len(a) {
+			x = a[i]
+		}
+		if i < len(b) {
+			y = b[i]
+		}
+		dst[i] = x ^ y
+	}
+	return dst
+}
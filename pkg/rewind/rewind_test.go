@@ -0,0 +1,155 @@
+/*
+		gemu - the gameboy emulator
+				<3 m0x
+	    __________________________
+	   |                          |
+	   | .----------------------. |
+	   | |  .----------------.  | |
+	   | |  |                |  | |
+	   | |))|                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  '----------------'  | |
+	   | |__GAME BOY____________/ |
+	   |          ________        |
+	   |    .    (Nintendo)       |
+	   |  _| |_   """"""""   .-.  |
+	   |-[_   _]-       .-. (   ) |
+	   |   |_|         (   ) '-'  |
+	   |    '           '-'   A   |
+	   |                 B        |
+	   |          ___   ___       |
+	   |         (___) (___)  ,., |
+	   |        select start ;:;: |
+	   |                    ,;:;' /
+	   |                   ,:;:'.'
+	   '-----------------------`
+*/
+package rewind_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"gemu/pkg/rewind"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// bufferCase pushes snapshots into a buffer holding Cap of them, then pops back through what's kept
+type bufferCase struct {
+	Cap       int        `json:"cap"`
+	Snapshots []snapshot `json:"snapshots"`
+}
+
+// snapshot is a machine state taken at Frame, Size bytes generated from Seed
+type snapshot struct {
+	Frame uint64 `json:"frame"`
+	Size  int    `json:"size"`
+	Seed  int    `json:"seed"`
+}
+
+// state generates the snapshot's bytes. Most of them only depend on the position, like most of the
+// machine staying the same between frames, with every eighth byte changing with the seed.
+func (s snapshot) state() []byte {
+	state := make([]byte, s.Size)
+	for i := range state {
+		state[i] = byte(i * 7)
+		if i%8 == 0 {
+			state[i] ^= byte(s.Seed*31 + i)
+		}
+	}
+	return state
+}
+
+// TestBuffer runs the cases in testdata, checking every snapshot comes back byte for byte
+func TestBuffer(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no fixtures")
+	}
+
+	for _, file := range files {
+		file := file
+		t.Run(strings.TrimSuffix(filepath.Base(file), ".json"), func(t *testing.T) {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var c bufferCase
+			if err := json.Unmarshal(data, &c); err != nil {
+				t.Fatal(err)
+			}
+			runCase(t, c)
+		})
+	}
+}
+
+// runCase pushes all of a case's snapshots, then pops back through the ones the buffer has room for
+func runCase(t *testing.T, c bufferCase) {
+	b := rewind.New(c.Cap)
+	if b.Cap() != c.Cap {
+		t.Fatalf("Cap() = %d, want %d", b.Cap(), c.Cap)
+	}
+
+	for i, s := range c.Snapshots {
+		if err := b.Push(s.Frame, s.state()); err != nil {
+			t.Fatalf("push %d: %v", i, err)
+		}
+		want := i + 1
+		if want > c.Cap {
+			want = c.Cap
+		}
+		if b.Len() != want {
+			t.Errorf("after push %d: Len() = %d, want %d", i, b.Len(), want)
+		}
+		checkLatest(t, b, s, "after push")
+	}
+
+	// Only the newest Cap snapshots are kept
+	kept := c.Snapshots
+	if len(kept) > c.Cap {
+		kept = kept[len(kept)-c.Cap:]
+	}
+	for i := len(kept) - 1; i >= 0; i-- {
+		if b.Len() != i+1 {
+			t.Errorf("popping back to frame %d: Len() = %d, want %d", kept[i].Frame, b.Len(), i+1)
+		}
+		checkLatest(t, b, kept[i], "popping back")
+		if err := b.Pop(); err != nil {
+			t.Fatalf("pop to before frame %d: %v", kept[i].Frame, err)
+		}
+	}
+
+	if b.Len() != 0 {
+		t.Errorf("emptied: Len() = %d, want 0", b.Len())
+	}
+	if _, _, ok := b.Latest(); ok {
+		t.Error("emptied: Latest() still has a snapshot")
+	}
+	if err := b.Pop(); err != nil {
+		t.Errorf("popping an empty buffer: %v", err)
+	}
+}
+
+// checkLatest checks the newest snapshot in the buffer is s, byte for byte
+func checkLatest(t *testing.T, b *rewind.Buffer, s snapshot, when string) {
+	t.Helper()
+	frame, state, ok := b.Latest()
+	if !ok {
+		t.Fatalf("%s to frame %d: no snapshot", when, s.Frame)
+	}
+	if frame != s.Frame {
+		t.Errorf("%s: frame %d, want %d", when, frame, s.Frame)
+	}
+	if want := s.state(); !bytes.Equal(state, want) {
+		t.Errorf("%s to frame %d: snapshot differs, %d bytes, want %d", when, s.Frame, len(state), len(want))
+	}
+}
@@ -0,0 +1,194 @@
+/*
+		gemu - the gameboy emulator
+				<3 m0x
+	    __________________________
+	   |                          |
+	   | .----------------------. |
+	   | |  .----------------.  | |
+	   | |  |                |  | |
+	   | |))|                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  '----------------'  | |
+	   | |__GAME BOY____________/ |
+	   |          ________        |
+	   |    .    (Nintendo)       |
+	   |  _| |_   """"""""   .-.  |
+	   |-[_   _]-       .-. (   ) |
+	   |   |_|         (   ) '-'  |
+	   |    '           '-'   A   |
+	   |                 B        |
+	   |          ___   ___       |
+	   |         (___) (___)  ,., |
+	   |        select start ;:;: |
+	   |                    ,;:;' /
+	   |                   ,:;:'.'
+	   '-----------------------`
+*/
+package savestate
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+/*	Save state format
+
+A save state is a header followed by sections, all little endian.
+
+Offset	Size	Description
+0		8		Magic, "GEMUSTAT"
+8		2		Version
+10		...		Sections
+
+Section	Size	Description
+0		4		Tag, e.g. "CPU " or "PPU "
+4		4		Length of the data
+8		...		Data, the fixed size state struct of the hardware it belongs to
+
+Sections can come in any order, and ones that aren't recognised are skipped, so new hardware
+can be added without breaking older states. Changing the layout of a section needs a new Version.
+*/
+
+// Magic identifies a save state
+const Magic = "GEMUSTAT"
+
+// Version is the version of the format that's written
+const Version = 1
+
+// order is the byte order everything is written in
+var order = binary.LittleEndian
+
+// Encoder writes a save state
+type Encoder struct {
+	w   io.Writer
+	err error
+}
+
+// NewEncoder starts writing a save state to w
+func NewEncoder(w io.Writer) *Encoder {
+	e := &Encoder{w: w}
+	e.write([]byte(Magic))
+	e.write(uint16(Version))
+	return e
+}
+
+// Section writes a section made up of the given values, which must be fixed size for encoding/binary
+func (e *Encoder) Section(tag string, values ...interface{}) {
+	if e.err != nil {
+		return
+	}
+	if len(tag) != 4 {
+		e.err = fmt.Errorf("section tag %q isn't 4 characters", tag)
+		return
+	}
+
+	var buf bytes.Buffer
+	for _, v := range values {
+		if err := binary.Write(&buf, order, v); err != nil {
+			e.err = fmt.Errorf("section %q: %w", tag, err)
+			return
+		}
+	}
+
+	e.write([]byte(tag))
+	e.write(uint32(buf.Len()))
+	e.write(buf.Bytes())
+}
+
+// Err returns the first error writing the state, if any
+func (e *Encoder) Err() error {
+	return e.err
+}
+
+func (e *Encoder) write(v interface{}) {
+	if e.err == nil {
+		e.err = binary.Write(e.w, order, v)
+	}
+}
+
+// ErrNotSaveState is returned when reading something that isn't a save state
+var ErrNotSaveState = errors.New("not a gemu save state")
+
+// Decoder reads a save state
+type Decoder struct {
+	version  uint16
+	sections map[string][]byte
+}
+
+// NewDecoder reads a whole save state from r, so it can be checked before anything is restored
+func NewDecoder(r io.Reader) (*Decoder, error) {
+	magic := make([]byte, len(Magic))
+	if _, err := io.ReadFull(r, magic); err != nil || string(magic) != Magic {
+		return nil, ErrNotSaveState
+	}
+
+	d := &Decoder{sections: make(map[string][]byte)}
+	if err := binary.Read(r, order, &d.version); err != nil {
+		return nil, err
+	}
+	if d.version > Version {
+		return nil, fmt.Errorf("save state is version %d, this version of gemu reads up to %d", d.version, Version)
+	}
+
+	for {
+		tag := make([]byte, 4)
+		if _, err := io.ReadFull(r, tag); err == io.EOF {
+			return d, nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		var length uint32
+		if err := binary.Read(r, order, &length); err != nil {
+			return nil, err
+		}
+		data := make([]byte, length)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, fmt.Errorf("section %q is truncated", tag)
+		}
+		d.sections[string(tag)] = data
+	}
+}
+
+// Version returns the version of the format the state was written in
+func (d *Decoder) Version() uint16 {
+	return d.version
+}
+
+// Has reports if the state has a section
+func (d *Decoder) Has(tag string) bool {
+	_, ok := d.sections[tag]
+	return ok
+}
+
+// Bytes returns the raw data of a section
+func (d *Decoder) Bytes(tag string) ([]byte, bool) {
+	data, ok := d.sections[tag]
+	return data, ok
+}
+
+// Section reads a section into the given values, which must match what it was written from
+func (d *Decoder) Section(tag string, values ...interface{}) error {
+	data, ok := d.sections[tag]
+	if !ok {
+		return fmt.Errorf("save state has no %q section", tag)
+	}
+
+	r := bytes.NewReader(data)
+	for _, v := range values {
+		if err := binary.Read(r, order, v); err != nil {
+			return fmt.Errorf("section %q: %w", tag, err)
+		}
+	}
+	if r.Len() != 0 {
+		return fmt.Errorf("section %q has %d bytes left over", tag, r.Len())
+	}
+	return nil
+}
@@ -29,3 +29,133 @@
 	   '-----------------------`
 */
 package serial
+
+import (
+	"gemu/pkg/interrupt"
+	"gemu/pkg/savestate"
+)
+
+/*	https://gbdev.io/pandocs/Serial_Data_Transfer_(Link_Cable).html
+
+A transfer shifts the 8 bits of SB out while shifting 8 bits in from the other GameBoy.
+With the internal clock, each bit takes 512 T-cycles (8192 Hz). When the transfer is done,
+bit 7 of SC is cleared and the Serial interrupt is requested.
+
+There is no link cable yet, so nothing is connected and every bit shifted in is a 1.
+
+Addr	Name	Description
+FF01	SB		Serial transfer data
+FF02	SC		Serial transfer control - bit 7 transfer start, bit 0 clock select (1 = internal)
+*/
+
+// Register addresses
+const (
+	SB = uint16(0xFF01)
+	SC = uint16(0xFF02)
+)
+
+// cyclesPerBit is how long it takes to shift a single bit with the internal clock
+const cyclesPerBit = 512
+
+// Serial is the serial port, used by the link cable
+type Serial struct {
+	sb, sc uint8
+
+	// T-cycles until the next bit is shifted, and how many bits are left to shift
+	cycles int
+	bits   int
+
+	request interrupt.Request
+
+	// OnSend, if set, is called with each byte sent with the internal clock.
+	// Test ROMs print their results this way.
+	OnSend func(value uint8)
+}
+
+// Init resets the serial port, interrupts are raised through request. OnSend is kept.
+func (s *Serial) Init(request interrupt.Request) {
+	*s = Serial{request: request, OnSend: s.OnSend}
+}
+
+// Tick advances the serial port by the given number of T-cycles
+func (s *Serial) Tick(cycles int) {
+	// Only transfers using the internal clock make progress, with nothing connected there is no external clock
+	if s.bits == 0 {
+		return
+	}
+
+	s.cycles -= cycles
+	for s.cycles <= 0 && s.bits > 0 {
+		s.sb = s.sb<<1 | 1
+		s.bits--
+		s.cycles += cyclesPerBit
+	}
+
+	if s.bits == 0 {
+		s.sc &^= 0x80
+		s.request(interrupt.Serial)
+	}
+}
+
+// Connected returns if there's another GameBoy on the other end of the link cable
+func (s *Serial) Connected() bool {
+	// There is no link cable yet
+	return false
+}
+
+// Transferring returns if a byte is being shifted out
+func (s *Serial) Transferring() bool {
+	return s.bits > 0
+}
+
+// Read returns the value of a serial register
+func (s *Serial) Read(addr uint16) uint8 {
+	switch addr {
+	case SB:
+		return s.sb
+	case SC:
+		// Unused bits read as 1
+		return s.sc | 0x7E
+	}
+	return 0xFF
+}
+
+// Write sets the value of a serial register, starting a transfer if requested
+func (s *Serial) Write(addr uint16, value uint8) {
+	switch addr {
+	case SB:
+		s.sb = value
+	case SC:
+		s.sc = value & 0x81
+		if s.sc == 0x81 {
+			s.bits = 8
+			s.cycles = cyclesPerBit
+			if s.OnSend != nil {
+				s.OnSend(s.sb)
+			}
+		} else {
+			s.bits = 0
+		}
+	}
+}
+
+// state is the serial port's section of a save state
+type state struct {
+	SB, SC       uint8
+	Cycles, Bits int32
+}
+
+// SaveState writes the serial port's state
+func (s *Serial) SaveState(e *savestate.Encoder) {
+	e.Section("SERL", state{SB: s.sb, SC: s.sc, Cycles: int32(s.cycles), Bits: int32(s.bits)})
+}
+
+// LoadState restores the serial port's state
+func (s *Serial) LoadState(d *savestate.Decoder) error {
+	var st state
+	if err := d.Section("SERL", &st); err != nil {
+		return err
+	}
+	s.sb, s.sc, s.cycles, s.bits = st.SB, st.SC, int(st.Cycles), int(st.Bits)
+	return nil
+}
@@ -0,0 +1,347 @@
+/*
+		gemu - the gameboy emulator
+				<3 m0x
+	    __________________________
+	   |                          |
+	   | .----------------------. |
+	   | |  .----------------.  | |
+	   | |  |                |  | |
+	   | |))|                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  '----------------'  | |
+	   | |__GAME BOY____________/ |
+	   |          ________        |
+	   |    .    (Nintendo)       |
+	   |  _| |_   """"""""   .-.  |
+	   |-[_   _]-       .-. (   ) |
+	   |   |_|         (   ) '-'  |
+	   |    '           '-'   A   |
+	   |                 B        |
+	   |          ___   ___       |
+	   |         (___) (___)  ,., |
+	   |        select start ;:;: |
+	   |                    ,;:;' /
+	   |                   ,:;:'.'
+	   '-----------------------`
+*/
+package symbols
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/*	https://rgbds.gbdev.io/docs/rgblink.1#Symbol_file
+
+RGBDS writes a .sym file alongside the ROM when linking with -n, one symbol per line as bank:address label.
+Comments start with a semicolon.
+
+	; File generated by rgblink
+	00:0150 Main
+	00:0153 Main.loop
+	01:4000 Bank1Func
+
+With -m it writes a .map file instead, which lists each bank's sections and the symbols in them.
+
+	ROMX bank #1:
+		SECTION: $4000-$40FF ($0100 bytes) ["Bank1"]
+		         $4000 = Bank1Func
+*/
+
+// Symbol is a label at an address in a bank
+type Symbol struct {
+	Bank int
+	Addr uint16
+	Name string
+}
+
+// key identifies a location in the banked address space
+type key struct {
+	bank int
+	addr uint16
+}
+
+// Table is a set of symbols, looked up by bank and address or by name
+type Table struct {
+	symbols []Symbol
+	byAddr  map[key]string
+	byName  map[string]Symbol
+
+	// symbols sorted by location, for finding the label before an address
+	sorted []Symbol
+}
+
+// New returns an empty table
+func New() *Table {
+	return &Table{byAddr: make(map[key]string), byName: make(map[string]Symbol)}
+}
+
+// Load loads a .sym or .map file, going by its extension
+func Load(path string) (*Table, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if strings.EqualFold(filepath.Ext(path), ".map") {
+		return ParseMap(f)
+	}
+	return Parse(f)
+}
+
+// LoadBeside loads the symbols for a ROM from a .sym or .map file with the same name, e.g. game.sym for game.gb.
+// It returns the path that was loaded, or a nil table and no error if there isn't one.
+func LoadBeside(romPath string) (*Table, string, error) {
+	base := strings.TrimSuffix(romPath, filepath.Ext(romPath))
+	for _, ext := range []string{".sym", ".map"} {
+		path := base + ext
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+		t, err := Load(path)
+		return t, path, err
+	}
+	return nil, "", nil
+}
+
+// Parse parses a .sym file
+func Parse(r io.Reader) (*Table, error) {
+	t := New()
+
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, ';'); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("line %d: expected bank:address label, got %q", n, line)
+		}
+
+		loc := strings.SplitN(fields[0], ":", 2)
+		if len(loc) != 2 {
+			return nil, fmt.Errorf("line %d: expected bank:address, got %q", n, fields[0])
+		}
+		bank, err := strconv.ParseUint(loc[0], 16, 16)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: bad bank %q", n, loc[0])
+		}
+		addr, err := strconv.ParseUint(loc[1], 16, 16)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: bad address %q", n, loc[1])
+		}
+
+		t.Add(Symbol{Bank: int(bank), Addr: uint16(addr), Name: fields[1]})
+	}
+
+	return t, scanner.Err()
+}
+
+var (
+	mapBankRegexp   = regexp.MustCompile(`^\s*(\w+) bank #(\d+):`)
+	mapSymbolRegexp = regexp.MustCompile(`^\s*\$([0-9A-Fa-f]{1,4}) = (\S+)`)
+)
+
+// ParseMap parses a .map file. Only the symbols are kept, sections and summaries are skipped.
+func ParseMap(r io.Reader) (*Table, error) {
+	t := New()
+	bank := -1
+
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+		if m := mapBankRegexp.FindStringSubmatch(line); m != nil {
+			b, err := strconv.Atoi(m[2])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: bad bank %q", n, m[2])
+			}
+			bank = b
+			continue
+		}
+		if m := mapSymbolRegexp.FindStringSubmatch(line); m != nil {
+			if bank < 0 {
+				return nil, fmt.Errorf("line %d: symbol %s outside of a bank", n, m[2])
+			}
+			addr, _ := strconv.ParseUint(m[1], 16, 16)
+			t.Add(Symbol{Bank: bank, Addr: uint16(addr), Name: m[2]})
+		}
+	}
+
+	return t, scanner.Err()
+}
+
+// Add adds a symbol to the table. The first symbol at a location wins lookups.
+func (t *Table) Add(s Symbol) {
+	t.symbols = append(t.symbols, s)
+
+	k := key{bankFor(s.Bank, s.Addr), s.Addr}
+	if _, ok := t.byAddr[k]; !ok {
+		t.byAddr[k] = s.Name
+	}
+	if _, ok := t.byName[s.Name]; !ok {
+		t.byName[s.Name] = s
+	}
+	t.sorted = nil
+}
+
+// Lookup returns the label at an address. The bank only matters for switchable ROM (4000-7FFF).
+func (t *Table) Lookup(bank int, addr uint16) (string, bool) {
+	if t == nil {
+		return "", false
+	}
+	name, ok := t.byAddr[key{bankFor(bank, addr), addr}]
+	return name, ok
+}
+
+// Nearest returns the closest symbol at or before an address, in the same bank and memory region, and how far past it addr is
+func (t *Table) Nearest(bank int, addr uint16) (Symbol, uint16, bool) {
+	if t == nil {
+		return Symbol{}, 0, false
+	}
+	if t.sorted == nil {
+		t.sorted = append([]Symbol(nil), t.symbols...)
+		sort.SliceStable(t.sorted, func(i, j int) bool {
+			a, b := t.sorted[i], t.sorted[j]
+			if ka, kb := bankFor(a.Bank, a.Addr), bankFor(b.Bank, b.Addr); ka != kb {
+				return ka < kb
+			}
+			return a.Addr < b.Addr
+		})
+	}
+
+	bank = bankFor(bank, addr)
+	i := sort.Search(len(t.sorted), func(i int) bool {
+		s := t.sorted[i]
+		if b := bankFor(s.Bank, s.Addr); b != bank {
+			return b > bank
+		}
+		return s.Addr > addr
+	})
+	if i == 0 {
+		return Symbol{}, 0, false
+	}
+
+	// Walk back over any other labels at the same address, so the first one added wins like Lookup
+	s := t.sorted[i-1]
+	for j := i - 2; j >= 0 && t.sorted[j].Addr == s.Addr && bankFor(t.sorted[j].Bank, s.Addr) == bank; j-- {
+		s = t.sorted[j]
+	}
+	if bankFor(s.Bank, s.Addr) != bank || region(s.Addr) != region(addr) {
+		return Symbol{}, 0, false
+	}
+	return s, addr - s.Addr, true
+}
+
+// Describe names an address as the nearest label plus an offset, e.g. "Main.loop+3", or "" if there isn't one
+func (t *Table) Describe(bank int, addr uint16) string {
+	s, off, ok := t.Nearest(bank, addr)
+	switch {
+	case !ok:
+		return ""
+	case off == 0:
+		return s.Name
+	default:
+		return fmt.Sprintf("%s+%d", s.Name, off)
+	}
+}
+
+// Find returns the symbol with a name
+func (t *Table) Find(name string) (Symbol, bool) {
+	if t == nil {
+		return Symbol{}, false
+	}
+	s, ok := t.byName[name]
+	return s, ok
+}
+
+// Resolve resolves a label with an optional offset, e.g. "Main.loop" or "Main.loop+3".
+// Offsets are hex when prefixed with 0x or $, decimal otherwise.
+func (t *Table) Resolve(expr string) (Symbol, error) {
+	name, offset := expr, ""
+	if i := strings.LastIndexAny(expr, "+-"); i > 0 {
+		name, offset = expr[:i], expr[i:]
+	}
+
+	s, ok := t.Find(name)
+	if !ok {
+		return Symbol{}, fmt.Errorf("unknown symbol %q", name)
+	}
+	if offset == "" {
+		return s, nil
+	}
+
+	digits, base := offset[1:], 10
+	if strings.HasPrefix(digits, "0x") || strings.HasPrefix(digits, "0X") {
+		digits, base = digits[2:], 16
+	} else if strings.HasPrefix(digits, "$") {
+		digits, base = digits[1:], 16
+	}
+	n, err := strconv.ParseUint(digits, base, 16)
+	if err != nil {
+		return Symbol{}, fmt.Errorf("bad offset %q", offset)
+	}
+	if offset[0] == '-' {
+		s.Addr -= uint16(n)
+	} else {
+		s.Addr += uint16(n)
+	}
+	s.Name = expr
+	return s, nil
+}
+
+// Len returns the number of symbols in the table
+func (t *Table) Len() int {
+	if t == nil {
+		return 0
+	}
+	return len(t.symbols)
+}
+
+// Symbols returns every symbol in the table, in the order they were added
+func (t *Table) Symbols() []Symbol {
+	return t.symbols
+}
+
+// region returns which block of the memory map an address is in, so labels aren't used past the end of their block
+func region(addr uint16) int {
+	switch {
+	case addr < 0x4000:
+		return 0 // ROM0
+	case addr < 0x8000:
+		return 1 // ROMX
+	case addr < 0xA000:
+		return 2 // VRAM
+	case addr < 0xC000:
+		return 3 // SRAM
+	case addr < 0xE000:
+		return 4 // WRAM
+	case addr < 0xFF80:
+		return 5 // Echo, OAM and IO, which shouldn't have labels
+	default:
+		return 6 // HRAM and IE
+	}
+}
+
+// bankFor returns the bank a symbol at addr is keyed under, only ROMX addresses are banked
+func bankFor(bank int, addr uint16) int {
+	if addr >= 0x4000 && addr <= 0x7FFF {
+		return bank
+	}
+	return 0
+}
@@ -0,0 +1,120 @@
+/*
+		gemu - the gameboy emulator
+				<3 m0x
+	    __________________________
+	   |                          |
+	   | .----------------------. |
+	   | |  .----------------.  | |
+	   | |  |                |  | |
+	   | |))|                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  '----------------'  | |
+	   | |__GAME BOY____________/ |
+	   |          ________        |
+	   |    .    (Nintendo)       |
+	   |  _| |_   """"""""   .-.  |
+	   |-[_   _]-       .-. (   ) |
+	   |   |_|         (   ) '-'  |
+	   |    '           '-'   A   |
+	   |                 B        |
+	   |          ___   ___       |
+	   |         (___) (___)  ,., |
+	   |        select start ;:;: |
+	   |                    ,;:;' /
+	   |                   ,:;:'.'
+	   '-----------------------`
+*/
+package testrom
+
+import (
+	"fmt"
+	"gemu/pkg/ppu"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+)
+
+/*	https://github.com/mattcurrie/dmg-acid2
+	https://github.com/mattcurrie/mealybug-tearoom-tests
+
+PPU tests can't check their own results, so they draw something and finish with LD B,B.
+The screen is then compared against a screenshot from real hardware, taken with GreyPalette.
+*/
+
+// compare compares a frame against a reference PNG, writing a diff image to diffPath if they differ
+func compare(frame *ppu.Frame, reference, diffPath string) (Status, string) {
+	want, err := loadPNG(reference)
+	if err != nil {
+		return StatusError, "failed to load reference - " + err.Error()
+	}
+	if b := want.Bounds(); b.Dx() != ppu.ScreenWidth || b.Dy() != ppu.ScreenHeight {
+		return StatusError, fmt.Sprintf("reference is %dx%d, expected %dx%d", b.Dx(), b.Dy(), ppu.ScreenWidth, ppu.ScreenHeight)
+	}
+
+	got := frame.Image(ppu.GreyPalette)
+	diff, n := diffImages(want, got)
+	if n == 0 {
+		return StatusPass, "matches " + reference
+	}
+
+	detail := fmt.Sprintf("%d pixels differ from %s", n, reference)
+	if diffPath != "" {
+		if err := savePNG(diffPath, diff); err != nil {
+			return StatusFail, detail + ", failed to write diff - " + err.Error()
+		}
+		detail += ", diff written to " + diffPath
+	}
+	return StatusFail, detail
+}
+
+// diffImages compares the colours of two images of the same size, ignoring alpha. The diff is the reference
+// faded out, with the pixels that differ in red.
+func diffImages(want image.Image, got *image.RGBA) (*image.RGBA, int) {
+	b := want.Bounds()
+	diff := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+
+	n := 0
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			w := color.RGBAModel.Convert(want.At(b.Min.X+x, b.Min.Y+y)).(color.RGBA)
+			g := got.RGBAAt(x, y)
+			if w.R != g.R || w.G != g.G || w.B != g.B {
+				n++
+				diff.SetRGBA(x, y, color.RGBA{0xFF, 0x00, 0x00, 0xFF})
+				continue
+			}
+
+			grey := uint8((uint16(w.R) + uint16(w.G) + uint16(w.B)) / 3)
+			faded := 0xC0 + grey/4
+			diff.SetRGBA(x, y, color.RGBA{faded, faded, faded, 0xFF})
+		}
+	}
+	return diff, n
+}
+
+func loadPNG(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return png.Decode(f)
+}
+
+func savePNG(path string, img image.Image) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(f, img); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
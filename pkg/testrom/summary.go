@@ -0,0 +1,62 @@
+/*
+		gemu - the gameboy emulator
+				<3 m0x
+	    __________________________
+	   |                          |
+	   | .----------------------. |
+	   | |  .----------------.  | |
+	   | |  |                |  | |
+	   | |))|                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  '----------------'  | |
+	   | |__GAME BOY____________/ |
+	   |          ________        |
+	   |    .    (Nintendo)       |
+	   |  _| |_   """"""""   .-.  |
+	   |-[_   _]-       .-. (   ) |
+	   |   |_|         (   ) '-'  |
+	   |    '           '-'   A   |
+	   |                 B        |
+	   |          ___   ___       |
+	   |         (___) (___)  ,., |
+	   |        select start ;:;: |
+	   |                    ,;:;' /
+	   |                   ,:;:'.'
+	   '-----------------------`
+*/
+package testrom
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+)
+
+// maxDetail is how much of a result's detail is shown in the summary table
+const maxDetail = 60
+
+// Summary writes a table of results, followed by a count of each status
+func Summary(w io.Writer, results []Result) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "STATUS\tROM\tFRAMES\tTIME\tDETAIL")
+
+	counts := make(map[Status]int)
+	for _, r := range results {
+		counts[r.Status]++
+
+		detail := r.Detail
+		if len(detail) > maxDetail {
+			detail = detail[:maxDetail-3] + "..."
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%s\n", r.Status, r.Path, r.Frames, r.Duration.Round(time.Millisecond), detail)
+	}
+	tw.Flush()
+
+	fmt.Fprintf(w, "\n%d passed, %d failed, %d timed out, %d errors, %d total\n",
+		counts[StatusPass], counts[StatusFail], counts[StatusTimeout], counts[StatusError], len(results))
+}
@@ -0,0 +1,294 @@
+/*
+		gemu - the gameboy emulator
+				<3 m0x
+	    __________________________
+	   |                          |
+	   | .----------------------. |
+	   | |  .----------------.  | |
+	   | |  |                |  | |
+	   | |))|                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  '----------------'  | |
+	   | |__GAME BOY____________/ |
+	   |          ________        |
+	   |    .    (Nintendo)       |
+	   |  _| |_   """"""""   .-.  |
+	   |-[_   _]-       .-. (   ) |
+	   |   |_|         (   ) '-'  |
+	   |    '           '-'   A   |
+	   |                 B        |
+	   |          ___   ___       |
+	   |         (___) (___)  ,., |
+	   |        select start ;:;: |
+	   |                    ,;:;' /
+	   |                   ,:;:'.'
+	   '-----------------------`
+*/
+package testrom
+
+import (
+	"fmt"
+	"gemu/pkg/cpu"
+	"gemu/pkg/gb"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+/*	https://github.com/retrio/gb-test-roms
+	https://github.com/Gekkio/mooneye-test-suite
+
+Test ROMs report their results in one of two ways.
+
+Blargg's ROMs print their results over the serial port, ending with "Passed" or "Failed".
+Newer ones also write them to cartridge RAM, with a signature so it can be told apart from garbage:
+
+Addr		Description
+A000		Status - 80 while running, then 00 for a pass or an error code
+A001-A003	Signature - DE B0 61
+A004-		Text output, zero terminated
+
+Mooneye's ROMs run LD B,B as a software breakpoint when they're done, with the Fibonacci numbers
+3, 5, 8, 13, 21, 34 in B, C, D, E, H, L for a pass and 42 in all of them for a failure.
+*/
+
+// Status is the outcome of running a test ROM
+type Status int
+
+const (
+	StatusPass    = Status(iota) // The ROM reported a pass
+	StatusFail                   // The ROM reported a failure
+	StatusTimeout                // The ROM didn't report anything before the time limit
+	StatusError                  // Emulation failed, e.g. on an unimplemented opcode
+)
+
+func (s Status) String() string {
+	switch s {
+	case StatusPass:
+		return "PASS"
+	case StatusFail:
+		return "FAIL"
+	case StatusTimeout:
+		return "TIMEOUT"
+	case StatusError:
+		return "ERROR"
+	default:
+		return fmt.Sprintf("Status(%d)", int(s))
+	}
+}
+
+// Result is the outcome of running a single test ROM
+type Result struct {
+	Path   string
+	Status Status
+
+	// Detail is what the ROM reported, or why it didn't report anything
+	Detail string
+
+	Frames   uint64
+	Duration time.Duration
+}
+
+// Options control how test ROMs are run
+type Options struct {
+	// The most frames to run a ROM for, and the most real time to spend on it. Zero means the default.
+	MaxFrames uint64
+	Timeout   time.Duration
+
+	// Run the boot ROM first, instead of starting in the state it leaves behind
+	BootROM bool
+
+	// Reference, if set, is a PNG the screen is compared against instead of looking for a reported result.
+	// The screenshot is taken after Frames frames, or at LD B,B if Frames is zero. If they differ,
+	// an image showing where is written to Diff, if it's set.
+	Reference string
+	Frames    uint64
+	Diff      string
+}
+
+// Defaults, two emulated minutes is plenty for the slowest of Blargg's ROMs
+const (
+	DefaultMaxFrames = 120 * 60
+	DefaultTimeout   = time.Minute
+)
+
+// mooneyePass is the Fibonacci signature in B, C, D, E, H, L for a pass
+var mooneyePass = [6]uint8{3, 5, 8, 13, 21, 34}
+
+// blarggSignature is written to A001-A003 by ROMs that report through cartridge RAM
+var blarggSignature = [3]uint8{0xDE, 0xB0, 0x61}
+
+// Run runs a single test ROM until it reports a result or runs out of time
+func Run(path string, opts Options) (r Result) {
+	if opts.MaxFrames == 0 {
+		opts.MaxFrames = DefaultMaxFrames
+	}
+	if opts.Timeout == 0 {
+		opts.Timeout = DefaultTimeout
+	}
+	if opts.Frames > opts.MaxFrames {
+		opts.MaxFrames = opts.Frames
+	}
+
+	start := time.Now()
+	r = Result{Path: path}
+	defer func() { r.Duration = time.Since(start) }()
+
+	gemu := &gb.GameBoy{Policy: gb.PolicyHalt}
+	if err := gemu.Init(nil); err != nil {
+		r.Status, r.Detail = StatusError, err.Error()
+		return r
+	}
+	if err := gemu.LoadROM(path); err != nil {
+		r.Status, r.Detail = StatusError, err.Error()
+		return r
+	}
+	if !opts.BootROM {
+		gemu.SkipBootROM()
+	}
+
+	var serial strings.Builder
+	gemu.MMU().Serial().OnSend = func(value uint8) {
+		serial.WriteByte(value)
+	}
+
+	// Catch Mooneye's LD B,B breakpoint before it executes, with the registers it reports through
+	var breakpoint *cpu.Registers
+	gemu.CPU().OnInstruction = func(reg cpu.Registers) {
+		if breakpoint == nil && gemu.MMU().Read(reg.PC) == 0x40 {
+			breakpoint = &reg
+		}
+	}
+
+	for r.Frames < opts.MaxFrames {
+		if time.Since(start) > opts.Timeout {
+			r.Status, r.Detail = StatusTimeout, fmt.Sprintf("no result after %s", opts.Timeout)
+			return r
+		}
+
+		// A ROM may well report its result and then run off into something gemu can't do, so check first
+		err := gemu.RunFrame()
+		r.Frames++
+
+		// Screenshot tests only care about when to take the screenshot
+		if opts.Reference != "" {
+			if (opts.Frames == 0 && breakpoint != nil) || (opts.Frames != 0 && r.Frames == opts.Frames) {
+				r.Status, r.Detail = compare(gemu.Frame(), opts.Reference, opts.Diff)
+				return r
+			}
+			if err != nil {
+				r.Status, r.Detail = StatusError, fmt.Sprintf("%s fault - %s", gb.Classify(err), err)
+				return r
+			}
+			continue
+		}
+
+		if breakpoint != nil {
+			reg := breakpoint
+			if [6]uint8{reg.B, reg.C, reg.D, reg.E, reg.H, reg.L} == mooneyePass {
+				r.Status, r.Detail = StatusPass, "Mooneye signature"
+			} else {
+				r.Status = StatusFail
+				r.Detail = fmt.Sprintf("Mooneye signature B:%02X C:%02X D:%02X E:%02X H:%02X L:%02X", reg.B, reg.C, reg.D, reg.E, reg.H, reg.L)
+			}
+			return r
+		}
+
+		if status, text, ok := blarggMemory(gemu); ok {
+			r.Status, r.Detail = StatusPass, clean(text)
+			if status != 0 {
+				r.Status = StatusFail
+				r.Detail = fmt.Sprintf("error code %d: %s", status, r.Detail)
+			}
+			return r
+		}
+
+		if out := serial.String(); strings.Contains(out, "Passed") {
+			r.Status, r.Detail = StatusPass, clean(out)
+			return r
+		} else if strings.Contains(out, "Failed") {
+			r.Status, r.Detail = StatusFail, clean(out)
+			return r
+		}
+
+		// A locked up CPU will never report anything
+		if err == nil && gemu.Locked() {
+			r.Status, r.Detail = StatusFail, fmt.Sprintf("the CPU locked up at %04X", gemu.CPU().Registers().PC)
+			if serial.Len() > 0 {
+				r.Detail += ", serial output: " + clean(serial.String())
+			}
+			return r
+		}
+		if err != nil {
+			r.Status, r.Detail = StatusError, fmt.Sprintf("%s fault - %s", gb.Classify(err), err)
+			if serial.Len() > 0 {
+				r.Detail += ", serial output: " + clean(serial.String())
+			}
+			return r
+		}
+	}
+
+	r.Status, r.Detail = StatusTimeout, fmt.Sprintf("no result after %d frames", opts.MaxFrames)
+	if serial.Len() > 0 {
+		r.Detail += ", serial output: " + clean(serial.String())
+	}
+	return r
+}
+
+// blarggMemory checks cartridge RAM for a finished result, returning the status code and text
+func blarggMemory(gemu *gb.GameBoy) (uint8, string, bool) {
+	mem := gemu.MMU()
+	for i, b := range blarggSignature {
+		if mem.Read(0xA001+uint16(i)) != b {
+			return 0, "", false
+		}
+	}
+
+	status := mem.Read(0xA000)
+	if status == 0x80 {
+		return 0, "", false
+	}
+
+	var text strings.Builder
+	for addr := uint16(0xA004); addr < 0xC000; addr++ {
+		b := mem.Read(addr)
+		if b == 0 {
+			break
+		}
+		text.WriteByte(b)
+	}
+	return status, text.String(), true
+}
+
+// clean squashes a ROM's multi-line output onto a single line
+func clean(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// Find returns the test ROMs under a path, which is either a ROM or a directory searched recursively
+func Find(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+
+	var roms []string
+	err = filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if ext := strings.ToLower(filepath.Ext(p)); !info.IsDir() && (ext == ".gb" || ext == ".gbc") {
+			roms = append(roms, p)
+		}
+		return nil
+	})
+	return roms, err
+}
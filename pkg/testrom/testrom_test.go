@@ -0,0 +1,125 @@
+/*
+		gemu - the gameboy emulator
+				<3 m0x
+	    __________________________
+	   |                          |
+	   | .----------------------. |
+	   | |  .----------------.  | |
+	   | |  |                |  | |
+	   | |))|                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  '----------------'  | |
+	   | |__GAME BOY____________/ |
+	   |          ________        |
+	   |    .    (Nintendo)       |
+	   |  _| |_   """"""""   .-.  |
+	   |-[_   _]-       .-. (   ) |
+	   |   |_|         (   ) '-'  |
+	   |    '           '-'   A   |
+	   |                 B        |
+	   |          ___   ___       |
+	   |         (___) (___)  ,., |
+	   |        select start ;:;: |
+	   |                    ,;:;' /
+	   |                   ,:;:'.'
+	   '-----------------------`
+*/
+package testrom
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestROMs runs every test ROM under GEMU_TEST_ROMS, a directory or a list of them separated like PATH.
+// It's skipped when the variable isn't set, as the ROMs aren't distributed with gemu.
+func TestROMs(t *testing.T) {
+	paths := os.Getenv("GEMU_TEST_ROMS")
+	if paths == "" {
+		t.Skip("GEMU_TEST_ROMS isn't set")
+	}
+
+	var roms []string
+	for _, path := range filepath.SplitList(paths) {
+		found, err := Find(path)
+		if os.IsNotExist(err) {
+			t.Skipf("%s doesn't exist", path)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		roms = append(roms, found...)
+	}
+	if len(roms) == 0 {
+		t.Skip("no test ROMs found")
+	}
+
+	var results []Result
+	for _, rom := range roms {
+		rom := rom
+		t.Run(strings.TrimSuffix(filepath.Base(rom), filepath.Ext(rom)), func(t *testing.T) {
+			r := Run(rom, Options{})
+			results = append(results, r)
+			if r.Status != StatusPass {
+				t.Errorf("%s: %s", r.Status, r.Detail)
+			}
+		})
+	}
+
+	var summary strings.Builder
+	Summary(&summary, results)
+	t.Log("\n" + summary.String())
+}
+
+// TestScreenshots runs every ROM under GEMU_SCREENSHOT_ROMS that has a reference PNG next to it with the same name,
+// e.g. dmg-acid2.gb and dmg-acid2.png, taking the screenshot at LD B,B. Diff images of failures are written to
+// GEMU_DIFF_DIR, or the temp directory.
+func TestScreenshots(t *testing.T) {
+	dir := os.Getenv("GEMU_SCREENSHOT_ROMS")
+	if dir == "" {
+		t.Skip("GEMU_SCREENSHOT_ROMS isn't set")
+	}
+	roms, err := Find(dir)
+	if os.IsNotExist(err) {
+		t.Skipf("%s doesn't exist", dir)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	diffDir := os.Getenv("GEMU_DIFF_DIR")
+	if diffDir == "" {
+		diffDir = os.TempDir()
+	}
+
+	var results []Result
+	for _, rom := range roms {
+		base := strings.TrimSuffix(rom, filepath.Ext(rom))
+		reference := base + ".png"
+		if _, err := os.Stat(reference); err != nil {
+			continue
+		}
+
+		rom := rom
+		t.Run(filepath.Base(base), func(t *testing.T) {
+			r := Run(rom, Options{Reference: reference, Diff: filepath.Join(diffDir, filepath.Base(base)+"-diff.png")})
+			results = append(results, r)
+			if r.Status != StatusPass {
+				t.Errorf("%s: %s", r.Status, r.Detail)
+			}
+		})
+	}
+	if len(results) == 0 {
+		t.Skip("no ROMs with reference screenshots found")
+	}
+
+	var summary strings.Builder
+	Summary(&summary, results)
+	t.Log("\n" + summary.String())
+}
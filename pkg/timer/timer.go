@@ -29,3 +29,147 @@
 	   '-----------------------`
 */
 package timer
+
+import (
+	"gemu/pkg/interrupt"
+	"gemu/pkg/savestate"
+)
+
+/*	https://gbdev.io/pandocs/Timer_and_Divider_Registers.html
+	https://gbdev.io/pandocs/Timer_Obscure_Behaviour.html
+
+The timer is driven by a 16-bit system counter that increments every T-cycle, DIV is its upper 8 bits.
+TIMA increments whenever the counter bit selected by TAC falls from 1 to 0 while the timer is enabled.
+When TIMA overflows it reads as 0x00 for one M-cycle, then it's reloaded from TMA and the Timer interrupt is requested.
+
+Addr	Name	Description
+FF04	DIV		Divider, writing any value resets it to 0
+FF05	TIMA	Timer counter
+FF06	TMA		Timer modulo, loaded into TIMA on overflow
+FF07	TAC		Timer control - bit 2 enable, bits 0-1 clock select
+*/
+
+// Register addresses
+const (
+	DIV  = uint16(0xFF04)
+	TIMA = uint16(0xFF05)
+	TMA  = uint16(0xFF06)
+	TAC  = uint16(0xFF07)
+)
+
+// tacBits maps the TAC clock select to the system counter bit that clocks TIMA
+var tacBits = [4]uint16{
+	9, // 4096 Hz
+	3, // 262144 Hz
+	5, // 65536 Hz
+	7, // 16384 Hz
+}
+
+// Timer is the DIV/TIMA timer
+type Timer struct {
+	counter        uint16 // System counter, DIV is the upper 8 bits
+	tima, tma, tac uint8
+
+	// T-cycles left until an overflowed TIMA is reloaded from TMA, 0 if no reload is pending
+	reload uint8
+
+	request interrupt.Request
+}
+
+// Init resets the timer, interrupts are raised through request
+func (t *Timer) Init(request interrupt.Request) {
+	*t = Timer{request: request}
+}
+
+// Tick advances the timer by the given number of T-cycles
+func (t *Timer) Tick(cycles int) {
+	for i := 0; i < cycles; i++ {
+		if t.reload > 0 {
+			t.reload--
+			if t.reload == 0 {
+				t.tima = t.tma
+				t.request(interrupt.Timer)
+			}
+		}
+
+		before := t.input()
+		t.counter++
+		if before && !t.input() {
+			t.increment()
+		}
+	}
+}
+
+// Read returns the value of a timer register
+func (t *Timer) Read(addr uint16) uint8 {
+	switch addr {
+	case DIV:
+		return uint8(t.counter >> 8)
+	case TIMA:
+		return t.tima
+	case TMA:
+		return t.tma
+	case TAC:
+		// Unused bits read as 1
+		return t.tac | 0xF8
+	}
+	return 0xFF
+}
+
+// Write sets the value of a timer register.
+// Resetting DIV or changing TAC can cause a falling edge, which increments TIMA just like the counter would.
+func (t *Timer) Write(addr uint16, value uint8) {
+	before := t.input()
+
+	switch addr {
+	case DIV:
+		t.counter = 0
+	case TIMA:
+		// Writing during the overflow cycle cancels the reload
+		t.tima = value
+		t.reload = 0
+	case TMA:
+		t.tma = value
+	case TAC:
+		t.tac = value & 0x07
+	}
+
+	if before && !t.input() {
+		t.increment()
+	}
+}
+
+// input is the signal that clocks TIMA, the selected counter bit ANDed with the enable bit
+func (t *Timer) input() bool {
+	return t.tac&0x04 != 0 && t.counter&(1<<tacBits[t.tac&0x03]) != 0
+}
+
+// increment increments TIMA, scheduling a reload from TMA when it overflows
+func (t *Timer) increment() {
+	t.tima++
+	if t.tima == 0 {
+		t.reload = 4
+	}
+}
+
+// state is the timer's section of a save state
+type state struct {
+	Counter        uint16
+	TIMA, TMA, TAC uint8
+	Reload         uint8
+}
+
+// SaveState writes the timer's state
+func (t *Timer) SaveState(e *savestate.Encoder) {
+	e.Section("TIMR", state{Counter: t.counter, TIMA: t.tima, TMA: t.tma, TAC: t.tac, Reload: t.reload})
+}
+
+// LoadState restores the timer's state
+func (t *Timer) LoadState(d *savestate.Decoder) error {
+	var s state
+	if err := d.Section("TIMR", &s); err != nil {
+		return err
+	}
+	t.counter, t.tima, t.tma, t.tac, t.reload = s.Counter, s.TIMA, s.TMA, s.TAC, s.Reload
+	return nil
+}
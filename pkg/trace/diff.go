@@ -0,0 +1,287 @@
+/*
+		gemu - the gameboy emulator
+				<3 m0x
+	    __________________________
+	   |                          |
+	   | .----------------------. |
+	   | |  .----------------.  | |
+	   | |  |                |  | |
+	   | |))|                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  '----------------'  | |
+	   | |__GAME BOY____________/ |
+	   |          ________        |
+	   |    .    (Nintendo)       |
+	   |  _| |_   """"""""   .-.  |
+	   |-[_   _]-       .-. (   ) |
+	   |   |_|         (   ) '-'  |
+	   |    '           '-'   A   |
+	   |                 B        |
+	   |          ___   ___       |
+	   |         (___) (___)  ,., |
+	   |        select start ;:;: |
+	   |                    ,;:;' /
+	   |                   ,:;:'.'
+	   '-----------------------`
+*/
+package trace
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"gemu/pkg/cpu"
+	"gemu/pkg/disasm"
+	"gemu/pkg/gb"
+	"gemu/pkg/mmu"
+	"io"
+	"os"
+	"strings"
+)
+
+// Field is a register or other field of a trace line that differs from the reference
+type Field struct {
+	Name      string
+	Want, Got string
+}
+
+// Divergence is where a run first differs from a reference trace
+type Divergence struct {
+	Line      int     // Line number in the reference, from 1
+	Want, Got string  // The reference line, and what gemu traced instead
+	Fields    []Field // The fields that differ
+
+	// The instruction before, which most likely caused the divergence. HasPrevious is false when the
+	// very first line differs, which means the run started in a different state to the reference.
+	Previous    string
+	HasPrevious bool
+}
+
+// Opcode returns the opcode and its name from the CPU's opcode table, for the instruction before the divergence.
+// CB prefixed opcodes are named by the sub-op after the prefix.
+func (d *Divergence) Opcode() (op uint8, name string, ok bool) {
+	if !d.HasPrevious {
+		return 0, "", false
+	}
+	mem := strings.Split(fields(d.Previous)["PCMEM"], ",")
+	n, err := parseHex(mem[0])
+	if err != nil {
+		return 0, "", false
+	}
+
+	op = uint8(n)
+	if op == 0xCB {
+		// The CPU's table only has the prefix, so name the sub-op that follows it
+		if len(mem) < 2 {
+			return op, "PREFIX CB", true
+		}
+		if n, err = parseHex(mem[1]); err != nil {
+			return op, "PREFIX CB", true
+		}
+		code := [2]uint8{op, uint8(n)}
+		ins := disasm.Decode(func(addr uint16) uint8 { return code[addr&1] }, 0)
+		return op, ins.Mnemonic, true
+	}
+	name, ok = cpu.OpcodeName(op)
+	return op, name, ok
+}
+
+// Differ compares each instruction a GameBoy runs against a reference trace, stopping at the first difference
+type Differ struct {
+	// Filter selects which instructions are compared, as references usually skip the boot ROM
+	Filter Filter
+
+	ref     *bufio.Scanner
+	closers []io.Closer
+	mem     *mmu.MMU
+
+	// The last lines gemu traced, oldest first
+	history []string
+	keep    int
+
+	line     int
+	diverged *Divergence
+	finished bool
+	err      error
+}
+
+// NewDiffer creates a differ reading the reference trace from r, which keeps the last keep lines gemu traced
+func NewDiffer(r io.Reader, keep int) *Differ {
+	ref := bufio.NewScanner(r)
+	ref.Buffer(make([]byte, 64*1024), 1024*1024)
+	return &Differ{Filter: Filter{Bank: -1}, ref: ref, keep: keep}
+}
+
+// OpenDiffer creates a differ reading the reference trace from a file, which may be gzip compressed
+func OpenDiffer(path string, keep int) (*Differ, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Sniff for the gzip magic number, rather than trusting the extension
+	br := bufio.NewReader(f)
+	magic, _ := br.Peek(2)
+	if !bytes.Equal(magic, []byte{0x1F, 0x8B}) {
+		d := NewDiffer(br, keep)
+		d.closers = []io.Closer{f}
+		return d, nil
+	}
+
+	gz, err := gzip.NewReader(br)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	d := NewDiffer(gz, keep)
+	d.closers = []io.Closer{gz, f}
+	return d, nil
+}
+
+// Attach starts comparing every instruction the GameBoy's CPU runs
+func (d *Differ) Attach(gemu *gb.GameBoy) {
+	d.mem = gemu.MMU()
+	gemu.CPU().OnInstruction = d.check
+}
+
+// check compares an instruction against the next reference line, and is the CPU's OnInstruction hook
+func (d *Differ) check(reg cpu.Registers) {
+	if d.Stopped() || !d.Filter.match(reg.PC, d.mem) {
+		return
+	}
+
+	want, ok := d.next()
+	if !ok {
+		return
+	}
+	got := Format(reg, d.mem.Read)
+
+	if diff := diffFields(want, got); len(diff) > 0 {
+		d.diverged = &Divergence{Line: d.line, Want: want, Got: got, Fields: diff}
+		if len(d.history) > 0 {
+			d.diverged.Previous, d.diverged.HasPrevious = d.history[len(d.history)-1], true
+		}
+	}
+
+	d.history = append(d.history, got)
+	if len(d.history) > d.keep+1 {
+		d.history = d.history[1:]
+	}
+}
+
+// Lockup records the CPU locking up at pc, after which it runs no more instructions. If the reference
+// goes on, that's a divergence at its next line.
+func (d *Differ) Lockup(pc uint16) {
+	if d.Stopped() {
+		return
+	}
+	want, ok := d.next()
+	if !ok {
+		return
+	}
+
+	got := fmt.Sprintf("CPU locked up at %04X", pc)
+	d.diverged = &Divergence{Line: d.line, Want: want, Got: got}
+	if len(d.history) > 0 {
+		d.diverged.Previous, d.diverged.HasPrevious = d.history[len(d.history)-1], true
+	}
+	d.history = append(d.history, got)
+}
+
+// next returns the next reference line, skipping blank ones
+func (d *Differ) next() (string, bool) {
+	for d.ref.Scan() {
+		d.line++
+		if line := strings.TrimSpace(d.ref.Text()); line != "" {
+			return line, true
+		}
+	}
+
+	d.err = d.ref.Err()
+	d.finished = true
+	return "", false
+}
+
+// Stopped reports if comparing has stopped, because of a divergence, the end of the reference or an error
+func (d *Differ) Stopped() bool {
+	return d.diverged != nil || d.finished || d.err != nil
+}
+
+// Diverged returns the first divergence, or nil if there hasn't been one
+func (d *Differ) Diverged() *Divergence {
+	return d.diverged
+}
+
+// Finished reports if every line of the reference has been matched
+func (d *Differ) Finished() bool {
+	return d.finished && d.diverged == nil && d.err == nil
+}
+
+// Err returns the error reading the reference, if any
+func (d *Differ) Err() error {
+	return d.err
+}
+
+// Lines returns how many lines of the reference have been read
+func (d *Differ) Lines() int {
+	return d.line
+}
+
+// History returns the last lines gemu traced, oldest first, not including a divergent line
+func (d *Differ) History() []string {
+	h := d.history
+	if d.diverged != nil && len(h) > 0 {
+		h = h[:len(h)-1]
+	}
+	if len(h) > d.keep {
+		h = h[len(h)-d.keep:]
+	}
+	return h
+}
+
+// Close closes the reference file
+func (d *Differ) Close() error {
+	var err error
+	for _, c := range d.closers {
+		if cerr := c.Close(); err == nil {
+			err = cerr
+		}
+	}
+	d.closers = nil
+	return err
+}
+
+// fields splits a trace line into its NAME:value fields
+func fields(line string) map[string]string {
+	m := make(map[string]string)
+	for _, f := range strings.Fields(line) {
+		if i := strings.IndexByte(f, ':'); i > 0 {
+			m[f[:i]] = f[i+1:]
+		}
+	}
+	return m
+}
+
+// diffFields returns the fields of the reference line that differ in gemu's. Fields gemu has that the reference
+// doesn't are ignored, so references without PCMEM still compare.
+func diffFields(want, got string) []Field {
+	g := fields(got)
+
+	var diff []Field
+	for _, f := range strings.Fields(want) {
+		i := strings.IndexByte(f, ':')
+		if i <= 0 {
+			continue
+		}
+		name, value := f[:i], f[i+1:]
+		if !strings.EqualFold(g[name], value) {
+			diff = append(diff, Field{Name: name, Want: value, Got: g[name]})
+		}
+	}
+	return diff
+}
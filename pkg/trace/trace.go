@@ -0,0 +1,224 @@
+/*
+		gemu - the gameboy emulator
+				<3 m0x
+	    __________________________
+	   |                          |
+	   | .----------------------. |
+	   | |  .----------------.  | |
+	   | |  |                |  | |
+	   | |))|                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  '----------------'  | |
+	   | |__GAME BOY____________/ |
+	   |          ________        |
+	   |    .    (Nintendo)       |
+	   |  _| |_   """"""""   .-.  |
+	   |-[_   _]-       .-. (   ) |
+	   |   |_|         (   ) '-'  |
+	   |    '           '-'   A   |
+	   |                 B        |
+	   |          ___   ___       |
+	   |         (___) (___)  ,., |
+	   |        select start ;:;: |
+	   |                    ,;:;' /
+	   |                   ,:;:'.'
+	   '-----------------------`
+*/
+package trace
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"gemu/pkg/cpu"
+	"gemu/pkg/gb"
+	"gemu/pkg/mmu"
+	"gemu/pkg/symbols"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+/*	https://github.com/robert/gameboy-doctor
+
+Traces are written one line per instruction, with the registers as they are before it executes
+and the 4 bytes at PC, which is the format Gameboy-Doctor and most reference emulators log in.
+
+	A:01 F:B0 B:00 C:13 D:00 E:D8 H:01 L:4D SP:FFFE PC:0100 PCMEM:00,C3,13,02
+*/
+
+// Format formats the state before an instruction as a trace line, without a newline
+func Format(reg cpu.Registers, read func(addr uint16) uint8) string {
+	pc := reg.PC
+	return fmt.Sprintf("A:%02X F:%02X B:%02X C:%02X D:%02X E:%02X H:%02X L:%02X SP:%04X PC:%04X PCMEM:%02X,%02X,%02X,%02X",
+		reg.A, reg.F, reg.B, reg.C, reg.D, reg.E, reg.H, reg.L, reg.SP, pc,
+		read(pc), read(pc+1), read(pc+2), read(pc+3))
+}
+
+// Range is an inclusive range of addresses
+type Range struct {
+	Start, End uint16
+}
+
+// ParseRanges parses comma separated hex ranges, e.g. "0150-01FF,4000-7FFF". A single address is a range of one.
+func ParseRanges(s string) ([]Range, error) {
+	var ranges []Range
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		bounds := strings.SplitN(part, "-", 2)
+		start, err := parseHex(bounds[0])
+		if err != nil {
+			return nil, fmt.Errorf("bad range %q", part)
+		}
+		end := start
+		if len(bounds) == 2 {
+			if end, err = parseHex(bounds[1]); err != nil {
+				return nil, fmt.Errorf("bad range %q", part)
+			}
+		}
+		if end < start {
+			return nil, fmt.Errorf("bad range %q, it ends before it starts", part)
+		}
+		ranges = append(ranges, Range{start, end})
+	}
+	return ranges, nil
+}
+
+// parseHex parses a hex address, with or without a $ or 0x prefix
+func parseHex(s string) (uint16, error) {
+	s = strings.TrimPrefix(strings.TrimPrefix(strings.ToLower(strings.TrimSpace(s)), "0x"), "$")
+	n, err := strconv.ParseUint(s, 16, 16)
+	return uint16(n), err
+}
+
+// Filter selects which instructions are traced
+type Filter struct {
+	// Only trace instructions at these addresses, or everywhere if empty
+	Ranges []Range
+
+	// Only trace instructions in this ROM bank, or every bank if negative.
+	// Bank 0 is 0000-3FFF, other banks are 4000-7FFF while they're mapped.
+	Bank int
+
+	// Trace the boot ROM too. Reference traces start at 0100 once it has run, so it's skipped by default.
+	BootROM bool
+}
+
+// match reports if the instruction at pc is traced
+func (f *Filter) match(pc uint16, mem *mmu.MMU) bool {
+	if !f.BootROM && pc < 0x100 && mem.BootROMMapped() {
+		return false
+	}
+
+	if f.Bank == 0 && pc >= 0x4000 {
+		return false
+	}
+	if f.Bank > 0 && (pc < 0x4000 || pc > 0x7FFF || mem.ROMBank() != f.Bank) {
+		return false
+	}
+
+	if len(f.Ranges) == 0 {
+		return true
+	}
+	for _, r := range f.Ranges {
+		if pc >= r.Start && pc <= r.End {
+			return true
+		}
+	}
+	return false
+}
+
+// Tracer writes an instruction trace for a GameBoy
+type Tracer struct {
+	Filter Filter
+
+	// Symbols, if set, are appended to each line as a comment, e.g. "; Main.loop+3".
+	// Reference traces don't have them, so leave them out when diffing.
+	Symbols *symbols.Table
+
+	mu      sync.Mutex
+	w       *bufio.Writer
+	closers []io.Closer
+	mem     *mmu.MMU
+	err     error
+}
+
+// New creates a tracer writing to w
+func New(w io.Writer) *Tracer {
+	return &Tracer{Filter: Filter{Bank: -1}, w: bufio.NewWriterSize(w, 64*1024)}
+}
+
+// Create creates a tracer writing to a file, gzip compressed if compress is set
+func Create(path string, compress bool) (*Tracer, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	if !compress {
+		t := New(f)
+		t.closers = []io.Closer{f}
+		return t, nil
+	}
+
+	gz := gzip.NewWriter(f)
+	t := New(gz)
+	t.closers = []io.Closer{gz, f}
+	return t, nil
+}
+
+// Attach starts tracing every instruction the GameBoy's CPU runs
+func (t *Tracer) Attach(gemu *gb.GameBoy) {
+	t.mem = gemu.MMU()
+	gemu.CPU().OnInstruction = t.trace
+}
+
+// trace writes a line for an instruction, and is the CPU's OnInstruction hook
+func (t *Tracer) trace(reg cpu.Registers) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.w == nil || t.err != nil || !t.Filter.match(reg.PC, t.mem) {
+		return
+	}
+
+	pc := reg.PC
+	_, t.err = t.w.WriteString(Format(reg, t.mem.Read))
+
+	if name := t.Symbols.Describe(t.mem.ROMBank(), pc); name != "" && !(pc < 0x100 && t.mem.BootROMMapped()) {
+		t.w.WriteString(" ; " + name)
+	}
+	t.w.WriteByte('\n')
+}
+
+// Close flushes the trace and closes its file. Instructions run after closing aren't traced.
+func (t *Tracer) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.w == nil {
+		return t.err
+	}
+
+	err := t.w.Flush()
+	for _, c := range t.closers {
+		if cerr := c.Close(); err == nil {
+			err = cerr
+		}
+	}
+	t.w = nil
+
+	if t.err != nil {
+		return t.err
+	}
+	return err
+}
@@ -0,0 +1,206 @@
+/*
+		gemu - the gameboy emulator
+				<3 m0x
+	    __________________________
+	   |                          |
+	   | .----------------------. |
+	   | |  .----------------.  | |
+	   | |  |                |  | |
+	   | |))|                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  '----------------'  | |
+	   | |__GAME BOY____________/ |
+	   |          ________        |
+	   |    .    (Nintendo)       |
+	   |  _| |_   """"""""   .-.  |
+	   |-[_   _]-       .-. (   ) |
+	   |   |_|         (   ) '-'  |
+	   |    '           '-'   A   |
+	   |                 B        |
+	   |          ___   ___       |
+	   |         (___) (___)  ,., |
+	   |        select start ;:;: |
+	   |                    ,;:;' /
+	   |                   ,:;:'.'
+	   '-----------------------`
+*/
+package video
+
+import (
+	"bufio"
+	"bytes"
+	"compress/lzw"
+	"gemu/pkg/ppu"
+	"image"
+	"math"
+	"os"
+)
+
+/*	GIF encoding
+
+image/gif can only encode a whole animation at once, which would mean keeping every frame until
+the recording ends. Instead frames are written as they go, which only takes one frame of memory
+however long the recording is. Every frame uses the one global colour table.
+
+https://www.w3.org/Graphics/GIF/spec-gif89a.txt
+*/
+
+// minDelay is the shortest a GIF frame is shown for, in hundredths of a second, that viewers respect
+const minDelay = 2
+
+// gifWriter records an animated GIF, writing each frame once it's known how long it's shown for
+type gifWriter struct {
+	f       *os.File
+	w       *bufio.Writer
+	palette *palette
+	depth   int // Bits per pixel of the colour table
+	frames  int
+	err     error
+
+	// The frame waiting for its delay, and when it started showing in hundredths of a second
+	pending *image.Paletted
+	start   float64
+
+	lzw bytes.Buffer
+}
+
+func createGIF(path string, p ppu.Palettes) (*gifWriter, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	g := &gifWriter{f: f, w: bufio.NewWriter(f), palette: newPalette(p), depth: 1}
+	for 1<<g.depth < len(g.palette.colors) {
+		g.depth++
+	}
+
+	g.writeHeader()
+	if g.err != nil {
+		f.Close()
+		return nil, g.err
+	}
+	return g, nil
+}
+
+// writeHeader writes the header, the colour table and the extension that makes the GIF loop
+func (g *gifWriter) writeHeader() {
+	g.write([]byte("GIF89a"))
+	g.write(le16(ppu.ScreenWidth))
+	g.write(le16(ppu.ScreenHeight))
+	g.write([]byte{0x80 | uint8(g.depth-1)<<4 | uint8(g.depth-1), 0, 0})
+
+	table := make([]byte, 3<<g.depth)
+	for i, c := range g.palette.colors {
+		r, gr, b, _ := c.RGBA()
+		table[i*3], table[i*3+1], table[i*3+2] = uint8(r>>8), uint8(gr>>8), uint8(b>>8)
+	}
+	g.write(table)
+
+	g.write([]byte{0x21, 0xFF, 0x0B})
+	g.write([]byte("NETSCAPE2.0"))
+	g.write([]byte{0x03, 0x01, 0x00, 0x00, 0x00})
+}
+
+// write writes to the file, remembering the first error
+func (g *gifWriter) write(b []byte) {
+	if g.err == nil {
+		_, g.err = g.w.Write(b)
+	}
+}
+
+// now returns the time the next frame starts showing, in hundredths of a second
+func (g *gifWriter) now() float64 {
+	return float64(g.frames) * 100 / FrameRate
+}
+
+func (g *gifWriter) WriteFrame(f *ppu.Frame) error {
+	img := g.palette.image(f)
+	now := g.now()
+	g.frames++
+
+	switch {
+	case g.pending == nil:
+		g.pending, g.start = img, now
+	case bytes.Equal(img.Pix, g.pending.Pix):
+		// Still showing the same thing
+	case delay(g.start, now) < minDelay:
+		// Too quick to be seen, this frame replaces it
+		g.pending = img
+	default:
+		g.flush(now)
+		g.pending, g.start = img, now
+	}
+	return g.err
+}
+
+// flush writes the pending frame, shown until end
+func (g *gifWriter) flush(end float64) {
+	d := delay(g.start, end)
+	if d < minDelay {
+		d = minDelay
+	}
+
+	// Graphic control extension, for the delay, then the image descriptor
+	g.write([]byte{0x21, 0xF9, 0x04, 0x00})
+	g.write(le16(d))
+	g.write([]byte{0x00, 0x00, 0x2C, 0, 0, 0, 0})
+	g.write(le16(ppu.ScreenWidth))
+	g.write(le16(ppu.ScreenHeight))
+	g.write([]byte{0x00})
+
+	// The pixels are LZW compressed, in blocks of up to 255 bytes
+	width := g.depth
+	if width < 2 {
+		width = 2
+	}
+	g.lzw.Reset()
+	lw := lzw.NewWriter(&g.lzw, lzw.LSB, width)
+	lw.Write(g.pending.Pix)
+	lw.Close()
+
+	g.write([]byte{uint8(width)})
+	data := g.lzw.Bytes()
+	for len(data) > 0 {
+		n := len(data)
+		if n > 255 {
+			n = 255
+		}
+		g.write([]byte{uint8(n)})
+		g.write(data[:n])
+		data = data[n:]
+	}
+	g.write([]byte{0x00})
+}
+
+// le16 returns a number as the 2 little endian bytes GIFs keep numbers in
+func le16(v int) []byte {
+	return []byte{uint8(v), uint8(v >> 8)}
+}
+
+// delay returns how long a frame is shown between two times, rounded so the delays add up exactly
+func delay(start, end float64) int {
+	return int(math.Round(end) - math.Round(start))
+}
+
+func (g *gifWriter) Close() error {
+	if g.pending != nil {
+		g.flush(g.now())
+		g.pending = nil
+	}
+	g.write([]byte{0x3B})
+	if g.err == nil {
+		g.err = g.w.Flush()
+	}
+	if err := g.f.Close(); g.err == nil {
+		g.err = err
+	}
+	return g.err
+}
+
+func (g *gifWriter) Frames() int {
+	return g.frames
+}
@@ -0,0 +1,74 @@
+/*
+		gemu - the gameboy emulator
+				<3 m0x
+	    __________________________
+	   |                          |
+	   | .----------------------. |
+	   | |  .----------------.  | |
+	   | |  |                |  | |
+	   | |))|                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  '----------------'  | |
+	   | |__GAME BOY____________/ |
+	   |          ________        |
+	   |    .    (Nintendo)       |
+	   |  _| |_   """"""""   .-.  |
+	   |-[_   _]-       .-. (   ) |
+	   |   |_|         (   ) '-'  |
+	   |    '           '-'   A   |
+	   |                 B        |
+	   |          ___   ___       |
+	   |         (___) (___)  ,., |
+	   |        select start ;:;: |
+	   |                    ,;:;' /
+	   |                   ,:;:'.'
+	   '-----------------------`
+*/
+package video
+
+import (
+	"gemu/pkg/ppu"
+	"image/png"
+	"os"
+	"path/filepath"
+)
+
+// pngWriter records a numbered sequence of PNGs into a directory, as it goes
+type pngWriter struct {
+	dir     string
+	palette *palette
+	encoder png.Encoder
+	frames  int
+}
+
+func createPNG(dir string, p ppu.Palettes) (*pngWriter, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &pngWriter{dir: dir, palette: newPalette(p), encoder: png.Encoder{CompressionLevel: png.BestSpeed}}, nil
+}
+
+func (w *pngWriter) WriteFrame(frame *ppu.Frame) error {
+	w.frames++
+	f, err := os.Create(filepath.Join(w.dir, name(w.frames)))
+	if err != nil {
+		return err
+	}
+	if err := w.encoder.Encode(f, w.palette.image(frame)); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (w *pngWriter) Close() error {
+	return nil
+}
+
+func (w *pngWriter) Frames() int {
+	return w.frames
+}
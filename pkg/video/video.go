@@ -0,0 +1,117 @@
+/*
+		gemu - the gameboy emulator
+				<3 m0x
+	    __________________________
+	   |                          |
+	   | .----------------------. |
+	   | |  .----------------.  | |
+	   | |  |                |  | |
+	   | |))|                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  |                |  | |
+	   | |  '----------------'  | |
+	   | |__GAME BOY____________/ |
+	   |          ________        |
+	   |    .    (Nintendo)       |
+	   |  _| |_   """"""""   .-.  |
+	   |-[_   _]-       .-. (   ) |
+	   |   |_|         (   ) '-'  |
+	   |    '           '-'   A   |
+	   |                 B        |
+	   |          ___   ___       |
+	   |         (___) (___)  ,., |
+	   |        select start ;:;: |
+	   |                    ,;:;' /
+	   |                   ,:;:'.'
+	   '-----------------------`
+*/
+package video
+
+import (
+	"fmt"
+	"gemu/pkg/ppu"
+	"image"
+	"image/color"
+	"path/filepath"
+	"strings"
+)
+
+/*	Video recording
+
+Gameplay is recorded without needing ffmpeg, as either an animated GIF or a numbered sequence of
+PNGs, which anything can turn into a video.
+
+The screen only ever has the 4 shades of each of its 3 palette registers, so frames are kept as
+paletted images with at most 12 colours. That makes GIFs tiny, and the PNGs are indexed too.
+
+GIF frame delays are in hundredths of a second, and most viewers show anything under 2 as 10, so
+GIFs top out at 50 frames a second. Frames shown for less time than that are dropped, and frames that
+are the same as the one before are merged into it, but the timing is kept exact overall. The PNG
+sequence has every frame.
+*/
+
+// FrameRate is the rate frames are recorded at, the GameBoy's ~59.73Hz
+const FrameRate = 4194304.0 / ppu.CyclesPerFrame
+
+// Writer records frames
+type Writer interface {
+	// WriteFrame adds the next frame
+	WriteFrame(f *ppu.Frame) error
+
+	// Close finishes the recording
+	Close() error
+
+	// Frames returns how many frames have been written
+	Frames() int
+}
+
+// Create starts a recording coloured with p. Paths ending in .gif are animated GIFs, anything else
+// is a directory the frames are written to as PNGs.
+func Create(path string, p ppu.Palettes) (Writer, error) {
+	if strings.EqualFold(filepath.Ext(path), ".gif") {
+		return createGIF(path, p)
+	}
+	return createPNG(path, p)
+}
+
+// palette turns frames into paletted images
+type palette struct {
+	colors color.Palette
+	index  [16]uint8 // Index into colors of each pixel value
+}
+
+func newPalette(p ppu.Palettes) *palette {
+	pal := new(palette)
+	for i := range pal.index {
+		c := p.Color(uint8(i))
+		found := false
+		for j, seen := range pal.colors {
+			if seen == color.Color(c) {
+				pal.index[i], found = uint8(j), true
+				break
+			}
+		}
+		if !found {
+			pal.index[i] = uint8(len(pal.colors))
+			pal.colors = append(pal.colors, c)
+		}
+	}
+	return pal
+}
+
+// image returns a frame as a paletted image
+func (pal *palette) image(f *ppu.Frame) *image.Paletted {
+	img := image.NewPaletted(image.Rect(0, 0, ppu.ScreenWidth, ppu.ScreenHeight), pal.colors)
+	for i, pixel := range f {
+		img.Pix[i] = pal.index[pixel&0x0F]
+	}
+	return img
+}
+
+// name returns the name of a PNG in a sequence
+func name(frame int) string {
+	return fmt.Sprintf("frame-%06d.png", frame)
+}